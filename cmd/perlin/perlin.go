@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"os"
+
 	gen "github.com/voidshard/cartographer/pkg/landscape"
 )
 
@@ -10,7 +14,21 @@ func main() {
 
 	cfg := gen.DefaultConfig()
 
-	w, err := gen.PerlinLandscape(cfg)
+	// optionally, the path to a JSON file of biome rules
+	if len(os.Args) > 1 {
+		rules, err := gen.LoadBiomeRulesFile(os.Args[1])
+		if err != nil {
+			panic(err)
+		}
+		cfg.Biome.Classifier = gen.ClassifierRules
+		cfg.Biome.Rules = rules
+	}
+
+	w, err := gen.PerlinLandscapeContext(context.Background(), cfg, func(p *gen.Progress) {
+		if p.Done {
+			log.Println(p.Stage, "took", p.Elapsed)
+		}
+	})
 	if err != nil {
 		panic(err)
 	}
@@ -20,5 +38,5 @@ func main() {
 		panic(err)
 	}
 
-	fmt.Println("rendered to", folder)
+	fmt.Println("rendered seed", cfg.Seed, "to", folder)
 }
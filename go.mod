@@ -11,5 +11,5 @@ require (
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 	github.com/pzsz/voronoi v0.0.0-20130609164533-4314be88c79f
 	github.com/stretchr/testify v1.7.0
-	golang.org/x/image v0.0.0-20211028202545-6944b10bf410 // indirect
+	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
 )
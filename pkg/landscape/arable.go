@@ -0,0 +1,150 @@
+package landscape
+
+import (
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+// crack following directions, clockwise (y increases downwards)
+var cracks = [4][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+
+// ArableRegions returns the outline of each contiguous region of land with
+// at least the given fertility, ignoring regions of fewer than `minPixels`
+// pixels. Outlines follow the edges of pixels (so a single pixel at 0,0 is
+// the square 0,0 -> 1,1) & trace only the outside of a region; a region
+// may surround land that is not arable.
+// If the map wraps east-west a region crossing the edge is a single outline
+// that runs on past the east edge of the map, save for a region that goes
+// all the way around the world which is cut at the edge.
+func (l *Landscape) ArableRegions(minFertility uint8, minPixels int) []*shapes.Polygon {
+	if l.fertility == nil {
+		return []*shapes.Polygon{}
+	}
+	x, y := l.fertility.Dimensions()
+
+	// nb. water, lava & bare rock are never arable
+	arable := func(i int) bool {
+		v := l.fertility.Value(i%x, i/x)
+		return v > 0 && v >= minFertility
+	}
+
+	// label each region (4 connected). We note the x of each pixel as we
+	// reach it (which may be off the map, if we wrap) so we can tell if a
+	// region reaches itself going all the way around the world
+	labels := make([]int, x*y)
+	ux := make([]int, x*y)
+	sizes := []int{0}
+	starts := []int{0}       // the top left pixel of each region, as we reached it
+	circles := []bool{false} // if the region goes all the way around
+	for i := range labels {
+		if labels[i] != 0 || !arable(i) {
+			continue
+		}
+		label := len(sizes)
+		sizes = append(sizes, 0)
+		starts = append(starts, i)
+		circles = append(circles, false)
+		labels[i] = label
+		ux[i] = i % x
+		queue := []int{i}
+		for n := 0; n < len(queue); n++ {
+			j := queue[n]
+			sizes[label]++
+			for _, d := range cracks {
+				k, ok := l.fertility.neighbour(j, d)
+				if !ok {
+					continue
+				}
+				if labels[k] == label && ux[k] != ux[j]+d[0] {
+					circles[label] = true
+				}
+				if labels[k] != 0 || !arable(k) {
+					continue
+				}
+				labels[k] = label
+				ux[k] = ux[j] + d[0]
+				queue = append(queue, k)
+				if k/x < starts[label]/x || (k/x == starts[label]/x && ux[k] < ux[starts[label]]) {
+					starts[label] = k
+				}
+			}
+		}
+	}
+
+	// trace each large enough region, starting from it's top left pixel
+	result := []*shapes.Polygon{}
+	for label := 1; label < len(sizes); label++ {
+		if sizes[label] < minPixels {
+			continue
+		}
+
+		if circles[label] {
+			// there is no outside edge to follow around the world, so we
+			// trace the region within the map
+			i := 0
+			for labels[i] != label {
+				i++
+			}
+			result = append(result, traceRegion(i%x, i/x, func(dx, dy int) bool {
+				return dx >= 0 && dy >= 0 && dx < x && dy < y && labels[dy*x+dx] == label
+			}))
+			continue
+		}
+
+		// we trace the region as we reached it, shifted so it starts on
+		// the map
+		i := starts[label]
+		shift := ux[i] - i%x
+		result = append(result, traceRegion(i%x, i/x, func(dx, dy int) bool {
+			j := l.fertility.wrap(dx)
+			if j < 0 || dy < 0 || j >= x || dy >= y {
+				return false
+			}
+			j += dy * x
+			return labels[j] == label && ux[j]-shift == dx
+		}))
+	}
+	return result
+}
+
+// traceRegion walks clockwise around the outside edge of the region
+// containing the pixel sx,sy, which must be the region's top left pixel.
+// Returns the corners of the outline.
+func traceRegion(sx, sy int, in func(dx, dy int) bool) *shapes.Polygon {
+	poly := shapes.NewPolygon([]*shapes.Point{shapes.Pt(float64(sx), float64(sy))})
+
+	// we keep the region on our right, starting along the top edge of sx,sy
+	cx, cy := sx, sy
+	dir := 0
+	for {
+		cx += cracks[dir][0]
+		cy += cracks[dir][1]
+		if cx == sx && cy == sy {
+			break
+		}
+
+		// the pixels ahead of us on our left & right
+		var lx, ly, rx, ry int
+		switch dir {
+		case 0: // east
+			lx, ly, rx, ry = cx, cy-1, cx, cy
+		case 1: // south
+			lx, ly, rx, ry = cx, cy, cx-1, cy
+		case 2: // west
+			lx, ly, rx, ry = cx-1, cy, cx-1, cy-1
+		case 3: // north
+			lx, ly, rx, ry = cx-1, cy-1, cx, cy-1
+		}
+
+		next := dir
+		if in(lx, ly) {
+			next = (dir + 3) % 4 // turn left
+		} else if !in(rx, ry) {
+			next = (dir + 1) % 4 // turn right
+		}
+		if next != dir {
+			poly.Add(shapes.Pt(float64(cx), float64(cy)))
+			dir = next
+		}
+	}
+	return poly
+}
@@ -0,0 +1,237 @@
+package landscape
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"path"
+)
+
+const (
+	// ArchiveVersion is the version of the archive format written by Save.
+	// Load refuses archives with a version it doesn't understand.
+	ArchiveVersion = 3
+
+	manifestFile = "manifest.json"
+	configFile   = "config.json"
+	poisFile     = "pois.json"
+	networkFile  = "rivers.json"
+	riverMapFile = "rivers.bin"
+	layerDir     = "layers"
+	riverDir     = "rivers"
+)
+
+// manifest describes the contents of an archive
+type manifest struct {
+	Version   int     `json:"version"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Layers    []Layer `json:"layers"`
+	RiverMaps int     `json:"rivermaps"`
+}
+
+// Save writes the landscape to the given writer as a single (tar) archive.
+// The archive holds a manifest, every layer we have (as a PNG), the pixels of each
+// river, our points of interest & the Config used to generate the landscape.
+func (l *Landscape) Save(w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	write := func(name string, data []byte) error {
+		err := tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0644,
+			Size: int64(len(data)),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+	writeJson := func(name string, v interface{}) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return write(name, data)
+	}
+	writePng := func(name string, im image.Image) error {
+		buff := new(bytes.Buffer)
+		err := png.Encode(buff, im)
+		if err != nil {
+			return err
+		}
+		return write(name, buff.Bytes())
+	}
+
+	x, y := l.Dimensions()
+	m := &manifest{
+		Version:   ArchiveVersion,
+		Width:     x,
+		Height:    y,
+		Layers:    []Layer{},
+		RiverMaps: len(l.rivermaps),
+	}
+	for _, name := range append(append([]Layer{}, builtinLayerNames...), l.CustomLayers()...) {
+		if l.Layer(name) == nil {
+			continue // eg. layers missing from older archives
+		}
+		m.Layers = append(m.Layers, name)
+	}
+
+	err := writeJson(manifestFile, m)
+	if err != nil {
+		return err
+	}
+
+	err = writeJson(configFile, l.config)
+	if err != nil {
+		return err
+	}
+
+	err = writeJson(poisFile, l.pointsOfInterest)
+	if err != nil {
+		return err
+	}
+
+	err = writeJson(networkFile, l.network)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range m.Layers {
+		err = writePng(path.Join(layerDir, string(name)+".png"), l.Layer(name))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = write(riverMapFile, encodeRiverMaps(l.rivermaps))
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// Load reads a landscape from an archive written by Save
+func Load(r io.Reader) (*Landscape, error) {
+	files := map[string][]byte{}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = data
+	}
+
+	readJson := func(name string, v interface{}) error {
+		data, ok := files[name]
+		if !ok {
+			return fmt.Errorf("archive missing %s", name)
+		}
+		return json.Unmarshal(data, v)
+	}
+	readPng := func(name string, x, y int, palette color.Palette) (*MapImage, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("archive missing %s", name)
+		}
+		im, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w decoding %s", err, name)
+		}
+		rect := im.Bounds()
+		if rect.Dx() != x || rect.Dy() != y {
+			return nil, fmt.Errorf("%s is %dx%d, expected %dx%d", name, rect.Dx(), rect.Dy(), x, y)
+		}
+		return newMapImageFrom(im, palette), nil
+	}
+
+	m := &manifest{}
+	err := readJson(manifestFile, m)
+	if err != nil {
+		return nil, err
+	}
+	if m.Version < 1 || m.Version > ArchiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d, expected <= %d", m.Version, ArchiveVersion)
+	}
+
+	l := &Landscape{
+		config:           &Config{},
+		pointsOfInterest: []*POI{},
+		network:          &RiverNetwork{Rivers: []*River{}},
+	}
+
+	err = readJson(configFile, l.config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = readJson(poisFile, &l.pointsOfInterest)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Version >= 2 { // version 1 archives have no river network
+		err = readJson(networkFile, l.network)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if l.config.Biome != nil {
+		l.setBiomeRules(l.config.Biome.Rules)
+	}
+
+	for _, name := range m.Layers {
+		palette := layerPalette(name)
+		if name == LayerBiomes || name == LayerBiomeSecondary {
+			palette = l.biomeIDs().palette
+		}
+		im, err := readPng(path.Join(layerDir, string(name)+".png"), m.Width, m.Height, palette)
+		if err != nil {
+			return nil, err
+		}
+		l.SetLayer(name, im)
+	}
+
+	rivers := []*riverMap{}
+	if m.Version >= 3 {
+		data, ok := files[riverMapFile]
+		if !ok {
+			return nil, fmt.Errorf("archive missing %s", riverMapFile)
+		}
+		rivers, err = decodeRiverMaps(m.Width, m.Height, m.RiverMaps, data)
+		if err != nil {
+			return nil, err
+		}
+	} else { // older archives hold an image per river
+		for i := 0; i < m.RiverMaps; i++ {
+			im, err := readPng(path.Join(riverDir, fmt.Sprintf("%d.png", i)), m.Width, m.Height, nil)
+			if err != nil {
+				return nil, err
+			}
+			rivers = append(rivers, riverMapFromImage(im))
+		}
+	}
+	l.setRiverMaps(m.Width, m.Height, rivers)
+	l.setWrapX(l.config.WrapX)
+
+	return l, nil
+}
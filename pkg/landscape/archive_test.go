@@ -0,0 +1,116 @@
+package landscape
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveLoad(t *testing.T) {
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	buff := new(bytes.Buffer)
+	assert.Nil(t, l.Save(buff))
+
+	result, err := Load(buff)
+	assert.Nil(t, err)
+
+	expect := layers(l)
+	for name, im := range layers(result) {
+		assertSameImage(t, name, expect[name], im)
+	}
+	assert.Equal(t, len(l.rivermaps), len(result.rivermaps))
+	for i := range l.rivermaps {
+		assert.Equal(t, l.rivermaps[i].pix, result.rivermaps[i].pix)
+		assertSameImage(t, "rivermap", l.RiverMap(i+1), result.RiverMap(i+1))
+	}
+	assert.Equal(t, l.riverIDs, result.riverIDs)
+	assert.Equal(t, l.PointsOfInterest(), result.PointsOfInterest())
+	assert.Equal(t, l.Config(), result.Config())
+	assert.Equal(t, l.Rivers(), result.Rivers())
+
+	x, y := l.Dimensions()
+	for dx := 0; dx < x; dx += 7 {
+		for dy := 0; dy < y; dy += 7 {
+			assert.Equal(t, l.RiverAt(dx, dy), result.RiverAt(dx, dy))
+		}
+	}
+}
+
+func TestLoadBadArchive(t *testing.T) {
+	_, err := Load(bytes.NewBufferString("not an archive"))
+	assert.NotNil(t, err)
+}
+
+// oldArchive writes l as a version 2 archive, which holds an image per river
+// & only the given layers
+func oldArchive(t *testing.T, l *Landscape, names []Layer) *bytes.Buffer {
+	buff := new(bytes.Buffer)
+	tw := tar.NewWriter(buff)
+	write := func(name string, data []byte) {
+		assert.Nil(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}))
+		_, err := tw.Write(data)
+		assert.Nil(t, err)
+	}
+	writeJson := func(name string, v interface{}) {
+		data, err := json.Marshal(v)
+		assert.Nil(t, err)
+		write(name, data)
+	}
+	writePng := func(name string, im *MapImage) {
+		data := new(bytes.Buffer)
+		assert.Nil(t, png.Encode(data, im))
+		write(name, data.Bytes())
+	}
+
+	x, y := l.Dimensions()
+	writeJson(manifestFile, &manifest{Version: 2, Width: x, Height: y, Layers: names, RiverMaps: len(l.rivermaps)})
+	writeJson(configFile, l.config)
+	writeJson(poisFile, l.pointsOfInterest)
+	writeJson(networkFile, l.network)
+	for _, name := range names {
+		writePng(layerDir+"/"+string(name)+".png", l.Layer(name))
+	}
+	for i := range l.rivermaps {
+		writePng(fmt.Sprintf("%s/%d.png", riverDir, i), l.RiverMap(i+1))
+	}
+	assert.Nil(t, tw.Close())
+	return buff
+}
+
+func TestSaveLoadOldArchive(t *testing.T) {
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	names := []Layer{LayerHeight, LayerSea, LayerRivers, LayerTemperature, LayerRainfall, LayerSwamp, LayerVolcanic, LayerBiomes}
+	old, err := Load(oldArchive(t, l, names))
+	assert.Nil(t, err)
+	assert.Nil(t, old.vegetation)
+	assert.Nil(t, old.geology)
+
+	// we can save it again, without the layers it never had
+	buff := new(bytes.Buffer)
+	assert.Nil(t, old.Save(buff))
+	result, err := Load(buff)
+	assert.Nil(t, err)
+
+	for _, name := range builtinLayerNames {
+		if result.Layer(name) == nil {
+			assert.NotContains(t, names, name)
+			continue
+		}
+		assertSameImage(t, string(name), l.Layer(name), result.Layer(name))
+	}
+	assert.Equal(t, l.riverIDs, result.riverIDs)
+	assert.Equal(t, l.Rivers(), result.Rivers())
+
+	a := result.At(10, 10)
+	assert.Equal(t, l.At(10, 10).Height, a.Height)
+	assert.Equal(t, uint8(0), a.Vegetation)
+}
@@ -2,18 +2,37 @@ package landscape
 
 // Area represents a specific small area of the map
 type Area struct {
-	// 0-255, where bigger numbers are higher/wetter/hotter/geothermally active
-	Height      uint8
-	Rainfall    uint8
-	Temperature uint8 // in degress c, offset so 100 => 0 degrees cel
+	// 0-255, where bigger numbers are higher/wetter/hotter/geothermally active.
+	// Height, Rainfall & Temperature are fractional (see MapImage.Float)
+	Height      float64
+	Rainfall    float64
+	Temperature float64 // in degress c, offset so 100 => 0 degrees cel
 	Volcanism   uint8
 
+	// the above in real world units, as given by the landscape's Scale
+	ElevationMeters    float64 // above (below, if negative) sea level
+	DepthMeters        float64 // of the sea, 0 if not sea
+	TemperatureCelsius float64
+	AnnualRainfallMM   float64
+
 	// if the square contains fresh/swamp/salt water/lava
 	Sea   bool
 	River bool
 	Swamp bool // as in swamp/stagnant water, generally implies a swamp biome
 	Lava  bool
 
+	// 0-255 how densely vegetated the area is, where 255 is dense forest
+	// & 0 is bare ground or water
+	Vegetation uint8
+
+	// the type of soil & how fertile (0-255) it is, where 255 is the
+	// richest farmland & 0 is barren
+	Soil      Soil
+	Fertility uint8
+
+	// the bedrock beneath the area
+	Rock Rock
+
 	// a general guess at the biome of the region as determined
 	// by temperatue, elevation, rainfall, presense of fresh water etc
 	Biome Biome
@@ -0,0 +1,34 @@
+package landscape
+
+import (
+	"testing"
+)
+
+func benchmarkPerlinLandscape(b *testing.B, size uint) {
+	cfg := DefaultConfig()
+	cfg.Seed = 16
+	cfg.Width = size
+	cfg.Height = size
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := PerlinLandscape(cfg)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPerlinLandscape1000(b *testing.B) { benchmarkPerlinLandscape(b, 1000) }
+
+func BenchmarkPerlinLandscape4000(b *testing.B) { benchmarkPerlinLandscape(b, 4000) }
+
+func BenchmarkMapImageValue(b *testing.B) {
+	im := NewMapImage(1000, 1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		eachPixel(im, func(dx, dy int, v uint8) {
+			im.SetValue(dx, dy, v+1)
+		})
+	}
+}
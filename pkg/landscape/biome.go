@@ -21,6 +21,14 @@ var (
 	Highlands       Biome = "highlands" // between mountainous & lowlands
 	ForestTemperate Biome = "forest-temperate"
 	ForestTropical  Biome = "forest-tropical"
+	Savanna         Biome = "savanna"   // hot grassland with scattered trees & a dry season
+	Steppe          Biome = "steppe"    // semi arid grassland
+	Taiga           Biome = "taiga"     // cold (boreal) coniferous forest
+	Grassland       Biome = "grassland" // temperate grassland / prairie
+	Shrubland       Biome = "shrubland" // dry summer scrub & woodland (mediterranean)
+	Mangrove        Biome = "mangrove"  // tropical coastal swamp
+	Alpine          Biome = "alpine"    // above the tree line on mountains
+	IceSheet        Biome = "ice-sheet" // permanent ice
 
 	frozenColor    color.Color = colornames.Antiquewhite
 	desertColor    color.Color = colornames.Brown
@@ -33,6 +41,14 @@ var (
 	highColor      color.Color = colornames.Yellow
 	forestTmpColor color.Color = colornames.Lightgreen
 	forestTrpColor color.Color = colornames.Green
+	savannaColor   color.Color = colornames.Darkkhaki
+	steppeColor    color.Color = colornames.Tan
+	taigaColor     color.Color = colornames.Darkgreen
+	grassColor     color.Color = colornames.Yellowgreen
+	shrubColor     color.Color = colornames.Olivedrab
+	mangroveColor  color.Color = colornames.Olive
+	alpineColor    color.Color = colornames.Lightsteelblue
+	iceColor       color.Color = colornames.White
 
 	biomecmap = map[Biome]color.Color{
 		Frozen:          frozenColor,
@@ -46,72 +62,224 @@ var (
 		Highlands:       highColor,
 		ForestTemperate: forestTmpColor,
 		ForestTropical:  forestTrpColor,
+		Savanna:         savannaColor,
+		Steppe:          steppeColor,
+		Taiga:           taigaColor,
+		Grassland:       grassColor,
+		Shrubland:       shrubColor,
+		Mangrove:        mangroveColor,
+		Alpine:          alpineColor,
+		IceSheet:        iceColor,
 	}
-	biomermap = map[color.Color]Biome{}
+
+	// builtinBiomes lists our built in biomes, a biome's ID (it's value in
+	// the biome layer) is it's index here. User defined biomes follow these.
+	builtinBiomes = []Biome{
+		Lowlands,
+		Frozen,
+		Desert,
+		Swampland,
+		Volcanic,
+		Sea,
+		Mountainous,
+		Tundra,
+		Highlands,
+		ForestTemperate,
+		ForestTropical,
+		Savanna,
+		Steppe,
+		Taiga,
+		Grassland,
+		Shrubland,
+		Mangrove,
+		Alpine,
+		IceSheet,
+	}
+
+	// biomePalette holds the default colour of each built in biome (by ID)
+	biomePalette color.Palette
+
+	// builtinBiomeTable is the biomeTable of a landscape without user defined biomes
+	builtinBiomeTable *biomeTable
 )
 
 func init() {
-	// reverse the map
-	for k, v := range biomecmap {
-		biomermap[v] = k
+	for _, b := range builtinBiomes {
+		biomePalette = append(biomePalette, biomecmap[b])
+	}
+	builtinBiomeTable = newBiomeTable(nil)
+}
+
+// biomeTable maps biome IDs (as stored in the biome layer) to biomes & the
+// colour each is rendered with. The colours are only used for rendering.
+type biomeTable struct {
+	biomes  []Biome
+	ids     map[Biome]int
+	palette color.Palette
+}
+
+// newBiomeTable returns a table of our built in biomes followed by those of
+// the given rules. A rule may recolour a built in biome, otherwise the first
+// rule naming a biome decides it's colour.
+func newBiomeTable(rules []*BiomeRule) *biomeTable {
+	t := &biomeTable{
+		biomes:  append([]Biome{}, builtinBiomes...),
+		ids:     map[Biome]int{},
+		palette: append(color.Palette{}, biomePalette...),
+	}
+	for i, b := range t.biomes {
+		t.ids[b] = i
+	}
+
+	seen := map[Biome]bool{}
+	for _, r := range rules {
+		c, err := parseColour(r.Colour)
+		if err != nil || seen[r.Name] {
+			continue
+		}
+		seen[r.Name] = true
+
+		id, ok := t.ids[r.Name]
+		if ok {
+			t.palette[id] = c
+			continue
+		}
+		t.ids[r.Name] = len(t.biomes)
+		t.biomes = append(t.biomes, r.Name)
+		t.palette = append(t.palette, c)
 	}
+	return t
+}
+
+// id returns the ID of the given biome, unknown biomes are Lowlands
+func (t *biomeTable) id(b Biome) uint8 {
+	return uint8(t.ids[b])
 }
 
-func toBiome(c color.Color) Biome {
-	b, ok := biomermap[c]
-	if ok {
-		return b
+// biome returns the biome with the given ID, or "" if there is no such biome
+func (t *biomeTable) biome(id uint8) Biome {
+	if int(id) >= len(t.biomes) {
+		return ""
 	}
-	return Lowlands // shrug
+	return t.biomes[id]
 }
 
-func (l *Landscape) determineBiomes(cfg *Config) {
-	x, y := l.rivers.Dimensions()
-	out := NewMapImage(x, y)
+// biomeIDs returns the table of biome IDs used in our biome layer
+func (l *Landscape) biomeIDs() *biomeTable {
+	if l.biomeTable == nil {
+		return builtinBiomeTable
+	}
+	return l.biomeTable
+}
+
+// setBiomeRules sets up the table used to read & render user defined biomes
+func (l *Landscape) setBiomeRules(rules []*BiomeRule) {
+	l.biomeTable = newBiomeTable(rules)
+}
 
-	eachPixel(out, func(dx, dy int, c uint8) {
-		if l.volcanic.Value(dx, dy) > 0 {
-			out.Set(dx, dy, volcColor)
-			return
+// biomeAt returns the biome at x,y
+func (l *Landscape) biomeAt(x, y int) Biome {
+	return l.biomeIDs().biome(l.biomes.Value(x, y))
+}
+
+// Biomes returns every biome the landscape knows of, including user defined
+// biomes. A biome's ID in the biome layer is it's index here.
+func (l *Landscape) Biomes() []Biome {
+	return append([]Biome{}, l.biomeIDs().biomes...)
+}
+
+// RenderBiomes returns a copy of the biome layer where each biome is drawn
+// in the given colour. Biomes without a colour use their usual colour.
+func (l *Landscape) RenderBiomes(colours map[Biome]color.Color) *MapImage {
+	t := l.biomeIDs()
+	pal := append(color.Palette{}, t.palette...)
+	for b, c := range colours {
+		id, ok := t.ids[b]
+		if ok {
+			pal[id] = c
 		}
+	}
 
-		height := l.height.Value(dx, dy)
-		temp := l.temperature.Value(dx, dy)
-		// drop temperature by 1 degree per 5 height units we climb
-		aboveSea := decrement(height, cfg.Sea.SeaLevel)
-		temp = decrement(temp, aboveSea/5)
-
-		rain := l.rainfall.Value(dx, dy)
-
-		if temp <= cfg.Biome.FrozenTemp {
-			out.Set(dx, dy, frozenColor)
-		} else if l.sea.Value(dx, dy) == 255 {
-			out.Set(dx, dy, seaColor)
-		} else if l.swamp.Value(dx, dy) == 255 || l.swamp.Value(dx, dy) == 120 {
-			// swamp takes precedence over tundra as slightly warmer tundra appears
-			// swamp-ish (eg "plains tundra") .. or water logged regions with no trees
-			// since their roots cannot sink into permafrost .. though during the summer
-			// months enough water does melt to pool in shallow bogs.
-			// https://www.youtube.com/watch?v=NL95ehsFb-4
-			out.Set(dx, dy, swampColor)
-		} else if temp <= cfg.Biome.TundraTemp {
-			// tundra tends to be a thin band of nearly perma frozen land
-			out.Set(dx, dy, tundraColor)
-		} else if temp >= cfg.Biome.DesertTemp && rain <= cfg.Biome.DesertRain {
-			out.Set(dx, dy, desertColor)
-		} else if temp >= cfg.Biome.ForestTropicalTemp && rain >= cfg.Biome.ForestTropicalRain {
-			out.Set(dx, dy, forestTrpColor)
-		} else if temp >= cfg.Biome.ForestTemperateTemp && rain >= cfg.Biome.ForestTemperateRain {
-			out.Set(dx, dy, forestTmpColor)
-		} else if uint(height) >= cfg.Biome.MountainHeight {
-			out.Set(dx, dy, mountColor)
-		} else if uint(height) >= cfg.Biome.HighlandsHeight {
-			out.Set(dx, dy, highColor)
-		} else {
-			out.Set(dx, dy, lowColor)
+	x, y := l.biomes.Dimensions()
+	out := newPalettedMapImage(x, y, pal)
+	copy(out.pix, l.biomes.pix)
+	return out
+}
+
+func (l *Landscape) determineBiomes(t *tracker, cfg *Config) error {
+	x, y := l.rivers.Dimensions()
+
+	l.setBiomeRules(cfg.Biome.Rules)
+	ids := l.biomeIDs()
+	out := newPalettedMapImage(x, y, ids.palette)
+
+	classify := l.defaultBiome
+	switch cfg.Biome.Classifier {
+	case ClassifierWhittaker:
+		classify = l.whittakerBiome
+	case ClassifierKoppen:
+		classify = l.koppenBiome
+	case ClassifierRules:
+		rc, err := newRuleClassifier(t, l, cfg.Biome.Rules)
+		if err != nil {
+			return err
 		}
+		classify = rc.biome
+	}
 
-	})
+	for dx := 0; dx < x; dx++ {
+		err := t.Progress(float64(dx) / float64(x))
+		if err != nil {
+			return err
+		}
+		for dy := 0; dy < y; dy++ {
+			out.SetValue(dx, dy, ids.id(classify(dx, dy, cfg)))
+		}
+	}
 
 	l.biomes = out
+	return nil
+}
+
+// defaultBiome decides the biome of a single pixel (ClassifierDefault)
+func (l *Landscape) defaultBiome(dx, dy int, cfg *Config) Biome {
+	if l.volcanic.Value(dx, dy) > 0 {
+		return Volcanic
+	}
+
+	height := l.height.Value(dx, dy)
+	temp := l.temperature.Value(dx, dy)
+	// drop temperature by 1 degree per 5 height units we climb
+	aboveSea := decrement(height, cfg.Sea.SeaLevel)
+	temp = decrement(temp, aboveSea/5)
+
+	rain := l.rainfall.Value(dx, dy)
+
+	if temp <= cfg.Biome.FrozenTemp {
+		return Frozen
+	} else if l.sea.Value(dx, dy) == 255 {
+		return Sea
+	} else if l.swamp.Value(dx, dy) == 255 || l.swamp.Value(dx, dy) == 120 {
+		// swamp takes precedence over tundra as slightly warmer tundra appears
+		// swamp-ish (eg "plains tundra") .. or water logged regions with no trees
+		// since their roots cannot sink into permafrost .. though during the summer
+		// months enough water does melt to pool in shallow bogs.
+		// https://www.youtube.com/watch?v=NL95ehsFb-4
+		return Swampland
+	} else if temp <= cfg.Biome.TundraTemp {
+		// tundra tends to be a thin band of nearly perma frozen land
+		return Tundra
+	} else if temp >= cfg.Biome.DesertTemp && rain <= cfg.Biome.DesertRain {
+		return Desert
+	} else if temp >= cfg.Biome.ForestTropicalTemp && rain >= cfg.Biome.ForestTropicalRain {
+		return ForestTropical
+	} else if temp >= cfg.Biome.ForestTemperateTemp && rain >= cfg.Biome.ForestTemperateRain {
+		return ForestTemperate
+	} else if uint(height) >= cfg.Biome.MountainHeight {
+		return Mountainous
+	} else if uint(height) >= cfg.Biome.HighlandsHeight {
+		return Highlands
+	} else {
+		return Lowlands
+	}
 }
@@ -0,0 +1,71 @@
+package landscape
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/colornames"
+)
+
+func TestBiomeTable(t *testing.T) {
+	table := newBiomeTable([]*BiomeRule{
+		{Name: "bog", Colour: "purple"}, // same colour as swamp
+		{Name: Desert, Colour: "#ff0000"},
+		{Name: "bog", Colour: "#000000"},
+	})
+
+	assert.Equal(t, len(builtinBiomes)+1, len(table.biomes))
+	for i, b := range table.biomes {
+		assert.Equal(t, b, table.biome(table.id(b)))
+		assert.Equal(t, uint8(i), table.id(b))
+	}
+	assert.Equal(t, Biome(""), table.biome(255))
+
+	// colours are only used for rendering, so may be shared
+	assert.Equal(t, table.palette[table.id(Swampland)], table.palette[table.id("bog")])
+	assert.NotEqual(t, table.id(Swampland), table.id("bog"))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, table.palette[table.id(Desert)])
+}
+
+func TestBiomeLayerRoundTrip(t *testing.T) {
+	table := newBiomeTable([]*BiomeRule{{Name: "bog", Colour: "purple"}})
+
+	im := newPalettedMapImage(3, 1, table.palette)
+	im.SetValue(0, 0, table.id(Swampland))
+	im.SetValue(1, 0, table.id("bog"))
+	im.SetValue(2, 0, table.id(Sea))
+
+	buff := new(bytes.Buffer)
+	assert.Nil(t, png.Encode(buff, im))
+	decoded, err := png.Decode(buff)
+	assert.Nil(t, err)
+
+	// swamp & bog share a colour but keep their IDs
+	cp := newMapImageFrom(decoded, table.palette)
+	assert.Equal(t, im.pix, cp.pix)
+}
+
+func TestRenderBiomes(t *testing.T) {
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	before := append([]uint8{}, l.biomes.pix...)
+	im := l.RenderBiomes(map[Biome]color.Color{Sea: colornames.Navy})
+
+	x, y := l.Dimensions()
+	for dx := 0; dx < x; dx++ {
+		for dy := 0; dy < y; dy++ {
+			b := l.At(dx, dy).Biome
+			assert.Equal(t, l.Biomes()[l.biomes.Value(dx, dy)], b)
+			if b == Sea {
+				assert.Equal(t, colornames.Navy, im.At(dx, dy))
+			} else {
+				assert.Equal(t, l.biomes.At(dx, dy), im.At(dx, dy))
+			}
+		}
+	}
+	assert.Equal(t, before, l.biomes.pix)
+}
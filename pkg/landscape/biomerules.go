@@ -0,0 +1,229 @@
+package landscape
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// BiomeRule defines a biome & the conditions an area must meet to be it.
+// Rules are checked in order of Priority (highest first) and the first rule
+// whose conditions all hold decides the biome. Conditions that are not
+// given always hold.
+type BiomeRule struct {
+	// name of the biome, as returned in Area.Biome
+	Name Biome `json:"name"`
+
+	// colour used to render the biome, either "#rrggbb" or a
+	// (lowercase) SVG colour name eg. "darkgreen". New biomes can't use
+	// the colour of a built in biome or another new biome.
+	Colour string `json:"colour"`
+
+	// rules with higher priority are checked first
+	Priority int `json:"priority"`
+
+	// height in metres above (below, if negative) sea level
+	Height *Bounds `json:"height,omitempty"`
+
+	// annual average temperature in c, adjusted for altitude
+	Temperature *Bounds `json:"temperature,omitempty"`
+
+	// annual rainfall in mm
+	Rainfall *Bounds `json:"rainfall,omitempty"`
+
+	// volcanism 0-255 where 255 is lava
+	Volcanism *Bounds `json:"volcanism,omitempty"`
+
+	// distance in pixels to the nearest river or lake
+	FreshWater *Bounds `json:"freshwater,omitempty"`
+
+	// if set the area must (or must not) be sea / swamp
+	Sea   *bool `json:"sea,omitempty"`
+	Swamp *bool `json:"swamp,omitempty"`
+}
+
+// Bounds is an inclusive range of values, either end may be left unset
+type Bounds struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// Contains returns if v is within the bounds
+func (b *Bounds) Contains(v float64) bool {
+	if b == nil {
+		return true
+	}
+	if b.Min != nil && v < *b.Min {
+		return false
+	}
+	if b.Max != nil && v > *b.Max {
+		return false
+	}
+	return true
+}
+
+// LoadBiomeRules reads a JSON list of BiomeRule from the given reader
+func LoadBiomeRules(r io.Reader) ([]*BiomeRule, error) {
+	rules := []*BiomeRule{}
+	err := json.NewDecoder(r).Decode(&rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, validateBiomeRules(rules)
+}
+
+// LoadBiomeRulesFile reads a JSON list of BiomeRule from the given file
+func LoadBiomeRulesFile(path string) ([]*BiomeRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadBiomeRules(f)
+}
+
+// validateBiomeRules checks each rule is named & has a valid colour, and that
+// we can fit all our biomes in a single (8 bit) layer
+func validateBiomeRules(rules []*BiomeRule) error {
+	// colours taken by new biomes so far (a biome may have many rules)
+	taken := map[Biome]color.Color{}
+	for i, r := range rules {
+		if r.Name == "" {
+			return fmt.Errorf("biome rule %d has no name", i)
+		}
+		c, err := parseColour(r.Colour)
+		if err != nil {
+			return fmt.Errorf("%w for biome %s", err, r.Name)
+		}
+
+		// a new biome can't share a colour with a built in one, else the two
+		// can't be told apart in a rendered map
+		_, builtin := builtinBiomeTable.ids[r.Name]
+		if builtin {
+			continue
+		}
+		for id, bc := range biomePalette {
+			if sameColour(c, bc) {
+				return fmt.Errorf("biome %s has the same colour as %s", r.Name, builtinBiomes[id])
+			}
+		}
+		for name, tc := range taken {
+			if name != r.Name && sameColour(c, tc) {
+				return fmt.Errorf("biome %s has the same colour as %s", r.Name, name)
+			}
+		}
+		taken[r.Name] = c
+	}
+	count := len(newBiomeTable(rules).biomes)
+	if count > 256 {
+		return fmt.Errorf("too many biomes %d, max 256", count)
+	}
+	return nil
+}
+
+// sameColour returns if a & b are the same colour
+func sameColour(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
+
+// parseColour reads a colour given as "#rrggbb" or an SVG colour name
+func parseColour(s string) (color.Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if strings.HasPrefix(s, "#") && len(s) == 7 {
+		v, err := strconv.ParseUint(s[1:], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid colour %q", s)
+		}
+		return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+	}
+	c, ok := colornames.Map[s]
+	if !ok {
+		return nil, fmt.Errorf("invalid colour %q", s)
+	}
+	return c, nil
+}
+
+// ruleClassifier decides biomes from a set of BiomeRule (ClassifierRules)
+type ruleClassifier struct {
+	l     *Landscape
+	rules []*BiomeRule
+
+	// distance to the nearest fresh water in pixels, nil if no rule needs it
+	fresh []int
+}
+
+// newRuleClassifier prepares the given rules for use on the landscape
+func newRuleClassifier(t *tracker, l *Landscape, rules []*BiomeRule) (*ruleClassifier, error) {
+	err := validateBiomeRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &ruleClassifier{
+		l:     l,
+		rules: append([]*BiomeRule{}, rules...),
+	}
+	sort.SliceStable(rc.rules, func(i, j int) bool { return rc.rules[i].Priority > rc.rules[j].Priority })
+
+	limit := -1
+	for _, r := range rules {
+		if r.FreshWater == nil {
+			continue
+		}
+		// we only need to know distances up to the largest bound
+		if r.FreshWater.Max == nil {
+			limit = math.MaxInt32
+		} else if int(*r.FreshWater.Max) > limit {
+			limit = int(*r.FreshWater.Max)
+		}
+	}
+	if limit >= 0 {
+		rc.fresh, err = distanceFrom(t, l.rivers, limit, isFreshWater)
+	}
+	return rc, err
+}
+
+// biome decides the biome of a single pixel, if no rule matches we fall
+// back to the default classifier
+func (rc *ruleClassifier) biome(dx, dy int, cfg *Config) Biome {
+	l := rc.l
+	x, _ := l.Dimensions()
+
+	height := l.ElevationMeters(dx, dy)
+	temp := l.TemperatureCelsius(dx, dy) - l.altitudeCooling(dx, dy)
+	rain := l.AnnualRainfallMM(dx, dy)
+	volc := float64(l.volcanic.Value(dx, dy))
+	sea := l.sea.Value(dx, dy) == 255
+	swamp := l.swamp.Value(dx, dy) == 255 || l.swamp.Value(dx, dy) == 120
+
+	for _, r := range rc.rules {
+		if r.Sea != nil && *r.Sea != sea {
+			continue
+		}
+		if r.Swamp != nil && *r.Swamp != swamp {
+			continue
+		}
+		if !r.Height.Contains(height) || !r.Temperature.Contains(temp) || !r.Rainfall.Contains(rain) || !r.Volcanism.Contains(volc) {
+			continue
+		}
+		if r.FreshWater != nil {
+			dist := rc.fresh[dy*x+dx]
+			if dist < 0 || !r.FreshWater.Contains(float64(dist)) {
+				continue // nb. -1 => further than we looked
+			}
+		}
+		return r.Name
+	}
+
+	return l.defaultBiome(dx, dy, cfg)
+}
@@ -0,0 +1,97 @@
+package landscape
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadBiomeRules(t *testing.T) {
+	rules, err := LoadBiomeRulesFile("testdata/biomes.json")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(rules))
+	assert.Equal(t, Biome("glacier"), rules[0].Name)
+	assert.Equal(t, 100, rules[0].Priority)
+	assert.True(t, rules[0].Temperature.Contains(-20))
+	assert.False(t, rules[0].Temperature.Contains(-5))
+	assert.False(t, *rules[0].Sea)
+	assert.Nil(t, rules[0].Swamp)
+
+	for _, in := range []string{
+		`[{"colour": "#ffffff"}]`,
+		`[{"name": "x", "colour": "#fffffz"}]`,
+		`[{"name": "x", "colour": "notacolour"}]`,
+		`[{"name": "x", "colour": "lightgreen"}]`, // forest-temperate's colour
+		`[{"name": "x", "colour": "#123456"}, {"name": "y", "colour": "#123456"}]`,
+		`{"name": "x"}`,
+	} {
+		_, err = LoadBiomeRules(strings.NewReader(in))
+		assert.NotNil(t, err, in)
+	}
+
+	// a biome may have many rules (of the same colour)
+	_, err = LoadBiomeRules(strings.NewReader(`[{"name": "x", "colour": "#123456"}, {"name": "x", "colour": "#123456"}]`))
+	assert.Nil(t, err)
+}
+
+func TestBiomeRules(t *testing.T) {
+	rules, err := LoadBiomeRulesFile("testdata/biomes.json")
+	assert.Nil(t, err)
+
+	cfg := testConfig()
+	cfg.Biome.Classifier = ClassifierRules
+	cfg.Biome.Rules = rules
+
+	l, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+
+	tr := newReporter(context.Background(), nil).stage(StageBiomes)
+	fresh, err := distanceFrom(tr, l.rivers, 3, isFreshWater)
+	assert.Nil(t, err)
+
+	x, y := l.Dimensions()
+	seen := map[Biome]int{}
+	banks := 0 // pixels that should be riverbank
+	for dx := 0; dx < x; dx++ {
+		for dy := 0; dy < y; dy++ {
+			a := l.At(dx, dy)
+			seen[a.Biome]++
+
+			d := fresh[dy*x+dx]
+			swamp := l.swamp.Value(dx, dy) == 255 || l.swamp.Value(dx, dy) == 120
+			if d >= 1 && !a.Sea && !swamp && a.Biome != "glacier" && a.Biome != Volcanic {
+				banks++
+				assert.Equal(t, Biome("riverbank"), a.Biome)
+			}
+
+			switch a.Biome {
+			case "riverbank":
+				assert.False(t, a.Sea)
+				assert.False(t, a.River)
+			case "meadow":
+				assert.False(t, a.Sea)
+			case "peaks":
+				assert.True(t, l.ElevationMeters(dx, dy) >= 3000)
+			}
+			if a.Sea {
+				assert.Contains(t, []Biome{Sea, Frozen, Volcanic, "peaks"}, a.Biome)
+			}
+		}
+	}
+	assert.Equal(t, banks, seen["riverbank"])
+	assert.True(t, seen["meadow"] > 0)
+
+	// user defined biomes survive a save & load
+	buff := new(bytes.Buffer)
+	assert.Nil(t, l.Save(buff))
+	loaded, err := Load(buff)
+	assert.Nil(t, err)
+	for dx := 0; dx < x; dx += 7 {
+		for dy := 0; dy < y; dy += 7 {
+			assert.Equal(t, l.At(dx, dy).Biome, loaded.At(dx, dy).Biome)
+		}
+	}
+}
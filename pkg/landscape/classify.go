@@ -0,0 +1,240 @@
+package landscape
+
+import (
+	"math"
+	"time"
+)
+
+// BiomeClassifier decides how we bucket areas into biomes
+type BiomeClassifier string
+
+const (
+	// ClassifierDefault uses the thresholds in biomeSettings
+	ClassifierDefault BiomeClassifier = "default"
+
+	// ClassifierWhittaker uses a Whittaker style lookup of annual average
+	// temperature & annual rainfall
+	ClassifierWhittaker BiomeClassifier = "whittaker"
+
+	// ClassifierKoppen uses the Köppen-Geiger climate classification, which
+	// considers how temperature & rainfall change through the year
+	ClassifierKoppen BiomeClassifier = "koppen"
+
+	// ClassifierRules uses the BiomeRule list given in biomeSettings.Rules
+	// (see LoadBiomeRules), falling back to ClassifierDefault
+	ClassifierRules BiomeClassifier = "rules"
+)
+
+// lapseRate is how much colder (in c) it gets per metre we climb
+const lapseRate = 6.5 / 1000
+
+// overrideBiome returns the biome of x,y if it is decided by something other
+// than the climate (sea, lava, swamp), given the temperature (c) of the
+// coldest & warmest months
+func (l *Landscape) overrideBiome(dx, dy int, coldest, warmest float64) (Biome, bool) {
+	if l.volcanic.Value(dx, dy) > 0 {
+		return Volcanic, true
+	}
+	if l.sea.Value(dx, dy) == 255 {
+		if warmest <= -2 {
+			return IceSheet, true
+		}
+		return Sea, true
+	}
+	swamp := l.swamp.Value(dx, dy)
+	if swamp == 255 || swamp == 120 {
+		if coldest >= 18 && l.continentalityAt(dx, dy) < 0.1 {
+			return Mangrove, true
+		}
+		return Swampland, true
+	}
+	return "", false
+}
+
+// altitudeCooling returns how much colder (c) x,y is than sea level due to height
+func (l *Landscape) altitudeCooling(dx, dy int) float64 {
+	return math.Max(l.ElevationMeters(dx, dy), 0) * lapseRate
+}
+
+// climate returns the temperature (c) & rainfall (mm) of each month at x,y
+// adjusted for altitude
+func (l *Landscape) climate(dx, dy int) ([12]float64, [12]float64) {
+	cooling := l.altitudeCooling(dx, dy)
+	temps := [12]float64{}
+	rain := [12]float64{}
+	for m := time.January; m <= time.December; m++ {
+		temps[m-1] = l.TemperatureAt(dx, dy, m) - cooling
+		rain[m-1] = l.RainfallAt(dx, dy, m)
+	}
+	return temps, rain
+}
+
+// whittakerBiome decides the biome of a single pixel (ClassifierWhittaker)
+func (l *Landscape) whittakerBiome(dx, dy int, cfg *Config) Biome {
+	temps, _ := l.climate(dx, dy)
+	coldest, warmest := minMax(temps[:])
+
+	b, ok := l.overrideBiome(dx, dy, coldest, warmest)
+	if ok {
+		return b
+	}
+
+	temp := l.TemperatureCelsius(dx, dy) - l.altitudeCooling(dx, dy)
+	b = whittaker(temp, l.AnnualRainfallMM(dx, dy))
+	if b == Tundra && uint(l.height.Value(dx, dy)) >= cfg.Biome.HighlandsHeight {
+		return Alpine
+	}
+	return b
+}
+
+// whittaker returns the biome for the given annual average temperature (c)
+// & annual rainfall (mm) following Whittaker's biome diagram
+func whittaker(temp, rain float64) Biome {
+	switch {
+	case temp < -15:
+		return IceSheet
+	case temp < -5:
+		return Tundra
+	case temp < 5: // boreal
+		if rain < 300 {
+			return Tundra
+		}
+		return Taiga
+	case temp < 20: // temperate
+		if rain < 250 {
+			return Desert
+		} else if rain < 500 {
+			return Grassland
+		} else if rain < 1000 {
+			return Shrubland
+		}
+		return ForestTemperate
+	default: // tropical / subtropical
+		if rain < 500 {
+			return Desert
+		} else if rain < 1500 {
+			return Savanna
+		}
+		return ForestTropical
+	}
+}
+
+// koppenBiome decides the biome of a single pixel (ClassifierKoppen)
+func (l *Landscape) koppenBiome(dx, dy int, cfg *Config) Biome {
+	temps, rain := l.climate(dx, dy)
+	coldest, warmest := minMax(temps[:])
+
+	b, ok := l.overrideBiome(dx, dy, coldest, warmest)
+	if ok {
+		return b
+	}
+
+	// the summer half of the year
+	_, y := l.Dimensions()
+	summer := [12]bool{}
+	for m := time.April; m <= time.September; m++ {
+		summer[m-1] = dy <= y/2
+	}
+	for _, m := range []time.Month{time.October, time.November, time.December, time.January, time.February, time.March} {
+		summer[m-1] = dy > y/2
+	}
+
+	b = koppen(temps, rain, summer)
+	if b == Tundra && uint(l.height.Value(dx, dy)) >= cfg.Biome.HighlandsHeight {
+		return Alpine
+	}
+	return b
+}
+
+// koppen returns the biome for the main Köppen-Geiger climate given the
+// temperature (c) & rainfall (mm) of each month & which months are in summer.
+//
+//	A (tropical)    Af, Am => tropical forest, Aw => savanna
+//	B (arid)        BW => desert, BS => steppe
+//	C (temperate)   Cs => shrubland, Cf, Cw => temperate forest
+//	D (continental) Dw => grassland, Df, Ds => temperate forest, or taiga if
+//	                summers are short
+//	E (polar)       ET => tundra, EF => ice sheet
+func koppen(temps, rain [12]float64, summer [12]bool) Biome {
+	coldest, warmest := minMax(temps[:])
+
+	annualTemp := 0.0
+	annualRain := 0.0
+	summerRain := 0.0
+	driestSummer, wettestSummer := math.Inf(1), 0.0
+	driestWinter, wettestWinter := math.Inf(1), 0.0
+	warmMonths := 0 // months over 10c
+	for m := 0; m < 12; m++ {
+		annualTemp += temps[m] / 12
+		annualRain += rain[m]
+		if temps[m] > 10 {
+			warmMonths++
+		}
+		if summer[m] {
+			summerRain += rain[m]
+			driestSummer = math.Min(driestSummer, rain[m])
+			wettestSummer = math.Max(wettestSummer, rain[m])
+		} else {
+			driestWinter = math.Min(driestWinter, rain[m])
+			wettestWinter = math.Max(wettestWinter, rain[m])
+		}
+	}
+
+	if warmest < 10 { // E
+		if warmest < 0 {
+			return IceSheet
+		}
+		return Tundra
+	}
+
+	// B, the threshold depends on when rain falls
+	threshold := 20*annualTemp + 140
+	if annualRain > 0 && summerRain/annualRain >= 0.7 {
+		threshold = 20*annualTemp + 280
+	} else if annualRain > 0 && summerRain/annualRain <= 0.3 {
+		threshold = 20 * annualTemp
+	}
+	if annualRain < threshold {
+		if annualRain < threshold/2 {
+			return Desert
+		}
+		return Steppe
+	}
+
+	driest, _ := minMax(rain[:])
+	if coldest >= 18 { // A
+		if driest >= 60 || driest >= 100-annualRain/25 {
+			return ForestTropical
+		}
+		return Savanna
+	}
+
+	drySummer := driestSummer < 40 && driestSummer < wettestWinter/3
+	dryWinter := driestWinter < wettestSummer/10
+
+	if coldest > -3 { // C
+		if drySummer {
+			return Shrubland
+		}
+		return ForestTemperate
+	}
+
+	// D
+	if warmMonths < 4 {
+		return Taiga
+	}
+	if dryWinter {
+		return Grassland
+	}
+	return ForestTemperate
+}
+
+// minMax returns the min & max of the given values
+func minMax(in []float64) (float64, float64) {
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, v := range in {
+		low = math.Min(low, v)
+		high = math.Max(high, v)
+	}
+	return low, high
+}
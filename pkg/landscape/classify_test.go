@@ -0,0 +1,114 @@
+package landscape
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhittaker(t *testing.T) {
+	cases := []struct {
+		Temp   float64
+		Rain   float64
+		Expect Biome
+	}{
+		{-20, 100, IceSheet},
+		{-10, 500, Tundra},
+		{0, 100, Tundra},
+		{0, 600, Taiga},
+		{10, 100, Desert},
+		{10, 400, Grassland},
+		{10, 800, Shrubland},
+		{10, 1500, ForestTemperate},
+		{25, 200, Desert},
+		{25, 1000, Savanna},
+		{25, 2500, ForestTropical},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.Expect, whittaker(c.Temp, c.Rain), "%v", c)
+	}
+}
+
+func TestKoppen(t *testing.T) {
+	// north hemisphere summer
+	summer := [12]bool{false, false, false, true, true, true, true, true, true, false, false, false}
+	flat := func(v float64) [12]float64 {
+		out := [12]float64{}
+		for i := range out {
+			out[i] = v
+		}
+		return out
+	}
+	// warm summers, cold winters
+	seasonal := func(mean, rng float64) [12]float64 {
+		out := [12]float64{}
+		for i := range out {
+			if summer[i] {
+				out[i] = mean + rng
+			} else {
+				out[i] = mean - rng
+			}
+		}
+		return out
+	}
+	// rain mostly in winter
+	dryFlip := func(wet, dry float64) [12]float64 {
+		out := [12]float64{}
+		for i := range out {
+			if summer[i] {
+				out[i] = dry
+			} else {
+				out[i] = wet
+			}
+		}
+		return out
+	}
+
+	cases := []struct {
+		Name   string
+		Temps  [12]float64
+		Rain   [12]float64
+		Expect Biome
+	}{
+		{"Af", flat(27), flat(200), ForestTropical},
+		{"Aw", flat(27), dryFlip(5, 250), Savanna},
+		{"BW", flat(25), flat(5), Desert},
+		{"BS", flat(20), flat(40), Steppe},
+		{"Cfb", seasonal(10, 6), flat(80), ForestTemperate},
+		{"Csa", seasonal(16, 7), dryFlip(120, 10), Shrubland},
+		{"Dfb", seasonal(3, 12), flat(60), ForestTemperate},
+		{"Dwa", seasonal(3, 12), dryFlip(3, 120), Grassland},
+		{"Dfc", [12]float64{-20, -18, -10, -2, 6, 13, 15, 12, 5, -3, -12, -18}, flat(60), Taiga},
+		{"ET", seasonal(-10, 15), flat(30), Tundra},
+		{"EF", flat(-30), flat(10), IceSheet},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.Expect, koppen(c.Temps, c.Rain, summer), c.Name)
+	}
+}
+
+func TestBiomeClassifiers(t *testing.T) {
+	for _, classifier := range []BiomeClassifier{ClassifierWhittaker, ClassifierKoppen} {
+		cfg := testConfig()
+		cfg.Biome.Classifier = classifier
+
+		l, err := PerlinLandscape(cfg)
+		assert.Nil(t, err)
+
+		x, y := l.Dimensions()
+		seen := map[Biome]bool{}
+		for dx := 0; dx < x; dx++ {
+			for dy := 0; dy < y; dy++ {
+				a := l.At(dx, dy)
+				assert.NotEqual(t, Biome(""), a.Biome)
+				if a.Sea {
+					assert.Contains(t, []Biome{Sea, IceSheet, Volcanic}, a.Biome)
+				}
+				seen[a.Biome] = true
+			}
+		}
+
+		// we should see a good spread of biomes, not just a couple
+		assert.True(t, len(seen) >= 5, "%s %v", classifier, seen)
+	}
+}
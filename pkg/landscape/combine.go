@@ -1,24 +1,18 @@
 package landscape
 
-import (
-	"image"
-)
-
 // imageCombiner is a simple util for adding images together with some
 // weights.
 type imageCombiner struct {
 	ix int
 	iy int
 
-	images  []image.Image
+	images  []*MapImage
 	weights []float64
 }
 
-func weight(im image.Image, w float64) func(*imageCombiner) {
+func weight(im *MapImage, w float64) func(*imageCombiner) {
 	return func(c *imageCombiner) {
-		rect := im.Bounds()
-		dx := rect.Max.X - rect.Min.X
-		dy := rect.Max.Y - rect.Min.Y
+		dx, dy := im.Dimensions()
 		if dx > c.ix {
 			c.ix = dx
 		}
@@ -33,7 +27,7 @@ func weight(im image.Image, w float64) func(*imageCombiner) {
 
 func combine(in ...func(*imageCombiner)) *MapImage {
 	ic := &imageCombiner{
-		images:  []image.Image{},
+		images:  []*MapImage{},
 		weights: []float64{},
 	}
 
@@ -48,16 +42,15 @@ func combine(in ...func(*imageCombiner)) *MapImage {
 	}
 
 	// and now we can build the final image
-	final := NewMapImage(ic.ix, ic.iy)
+	final := NewMapImage16(ic.ix, ic.iy)
 
 	for dx := 0; dx < ic.ix; dx++ {
 		for dy := 0; dy < ic.iy; dy++ {
 			value := 0.0
 			for i, im := range ic.images {
-				c, _, _, _ := im.At(dx, dy).RGBA()
-				value += (float64(c/255) * (ic.weights[i] / sumOfWeights))
+				value += im.Float(dx, dy) * (ic.weights[i] / sumOfWeights)
 			}
-			final.SetValue(dx, dy, toUint8(value))
+			final.SetFloat(dx, dy, value)
 		}
 	}
 
@@ -1,20 +1,47 @@
 package landscape
 
+import (
+	"image"
+	"time"
+
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
 type Config struct {
+	// seed for all random number generation, the same seed & settings
+	// will always produce the same maps
+	Seed int64
+
 	// base map width
 	Width uint
 	// base map height
 	Height uint
 
+	// if set the map wraps east-west (the left edge meets the right edge)
+	// like a globe, so there is no seam where the edges meet
+	WrapX bool
+
+	// converts map values to real world units
+	Scale *Scale
+
 	Lakes    *lakeSettings
 	Rain     *rainfallSettings
 	Temp     *tempSettings
 	Rivers   *riverSettings
 	Land     *landSettings
+	Mask     *maskSettings
+	Erosion  *erosionSettings
+	Thermal  *thermalSettings
 	Sea      *seaSettings
+	Ocean    *oceanSettings
+	Seasons  *seasonSettings
 	Volcanic *volcSettings
 	Swamp    *swampSettings
 	Biome    *biomeSettings
+
+	Vegetation *vegetationSettings
+	Soil       *soilSettings
+	Geology    *geologySettings
 }
 
 type lakeSettings struct {
@@ -92,9 +119,30 @@ type tempSettings struct {
 
 type rainfallSettings struct {
 	RainfallVariance float64
+
+	// how prevailing winds blow (WindBands, WindFixed or WindNone)
+	Wind WindMode
+
+	// the direction the wind blows towards (WindFixed only)
+	WindHeading shapes.Heading
+
+	// 0-1 how much moisture air picks up over each pixel of sea
+	Evaporation float64
+
+	// 0-1 how much moisture air drops over each pixel of land
+	Precipitation float64
+
+	// 0-1 how much moisture air drops per unit of height it climbs
+	Orographic float64
+
+	// 0-1 how much of our rainfall is noise, rather than carried on the wind
+	NoiseWeight float64
 }
 
 type riverSettings struct {
+	// how rivers are generated (RiverWalk or RiverFlow)
+	Mode RiverMode
+
 	// number of rivers (max), we do not guarantee this many
 	Number uint
 
@@ -110,6 +158,63 @@ type riverSettings struct {
 
 	// TurnChance is how likely a river is to change direction on a given pixel
 	TurnChance float64
+
+	// -- RiverFlow only settings --
+	// number of pixels that must drain through a pixel for it to be river
+	FlowThreshold uint
+
+	// scales river width by how much water flows through it, a river
+	// carrying 4x FlowThreshold is FlowWidth*2 pixels wide
+	FlowWidth float64
+}
+
+// erosionSettings controls hydraulic erosion, where we simulate
+// droplets of water rolling over the heightmap moving sediment around.
+type erosionSettings struct {
+	// number of droplets to simulate, 0 disables erosion
+	Iterations uint
+
+	// how long (in steps) a droplet lives
+	MaxLifetime uint
+
+	// 0-1 how much a droplet keeps going in it's current direction
+	// rather than rolling downhill. Higher values -> straighter valleys
+	Inertia float64
+
+	// multiplier for how much sediment a droplet can carry
+	Capacity float64
+
+	// used to prevent droplets carrying no sediment on flat ground
+	MinSlope float64
+
+	// 0-1 how much excess sediment a droplet drops when over capacity
+	Deposition float64
+
+	// 0-1 how much of the remaining capacity a droplet fills by eroding
+	Erosion float64
+
+	// 0-1 how much water a droplet loses each step
+	Evaporation float64
+
+	// how fast droplets accelerate downhill
+	Gravity float64
+
+	// radius of the area about a droplet that it erodes
+	Radius uint
+}
+
+// thermalSettings controls thermal erosion, where material on slopes that are
+// too steep slides down onto it's neighbours.
+type thermalSettings struct {
+	// number of passes over the heightmap, 0 disables thermal erosion
+	Iterations uint
+
+	// max difference in height between neighbouring pixels before
+	// material starts to slide (the talus angle)
+	Talus float64
+
+	// 0-1 how much of the excess material moves each pass
+	Rate float64
 }
 
 type seaSettings struct {
@@ -117,15 +222,177 @@ type seaSettings struct {
 	SeaLevel uint8
 }
 
+// oceanSettings controls ocean currents & how the sea moderates
+// the temperature of nearby land
+type oceanSettings struct {
+	// if false, we don't determine currents
+	Currents bool
+
+	// max degrees c warmer / colder a current makes the sea
+	CurrentTemp float64
+
+	// how far (in pixels) from a coast currents are turned along it
+	CurrentRange uint
+
+	// 0-1 how much land on the coast takes the temperature of the sea
+	CoastalModeration float64
+
+	// how far (in pixels) inland the sea moderates temperature
+	CoastalRange uint
+}
+
+// seasonSettings controls how temperature & rainfall change through the year
+type seasonSettings struct {
+	// tilt of the world in degrees, 0 means no seasons (Earth is ~23.44)
+	AxialTilt float64
+
+	// max difference (in c) between the annual average temperature and
+	// that of midsummer / midwinter. Seen near the poles far from the sea.
+	TempRange float64
+
+	// 0-1 how much more (or less) rain falls in summer (winter) in the tropics
+	Monsoon float64
+
+	// how far (in pixels) from the sea we have to go before we have a
+	// fully continental climate
+	ContinentalRange uint
+}
+
+// vegetationSettings controls how densely plants grow
+type vegetationSettings struct {
+	// annual rainfall (mm) needed for the densest growth
+	Rainfall float64
+
+	// 0-1 how much being next to a river or lake counts towards the water
+	// plants need, fading to nothing at biomeSettings.FreshWaterRadius
+	FreshWater float64
+
+	// temperature (c) below which nothing grows & the temperature at or
+	// above which growth is densest
+	MinTemp     float64
+	OptimalTemp float64
+
+	// slope (rise over run) at which nothing grows, 1 => 45 degrees
+	MaxSlope float64
+
+	// height (m above sea level) of the tree line & how far above it (m)
+	// plants thin out to nothing
+	TreeLine     float64
+	TreeLineFade float64
+}
+
+// soilSettings controls how we decide soil types & fertility
+type soilSettings struct {
+	// how far (in pixels) from a river it's floodplain extends, per stream
+	// order of the river (so bigger rivers have wider floodplains)
+	FloodplainRange uint
+
+	// how far above a river (in height units) land can be & still flood
+	FloodplainHeight float64
+
+	// how far (in pixels) from volcanic land we find volcanic soil
+	VolcanicRange uint
+
+	// slope (rise over run) over which soil is thin & rocky
+	RockySlope float64
+
+	// temperature (c) below which the ground is permafrost
+	FrozenTemp float64
+
+	// annual rainfall (mm) below which soil is dry & sandy
+	DryRain float64
+
+	// annual rainfall (mm) over which rain washes nutrients out of the soil
+	LeachingRain float64
+}
+
+// geologySettings controls bedrock & mineral deposits
+type geologySettings struct {
+	// number of geological provinces, each with it's own bedrock
+	Provinces uint
+
+	// how far (in pixels) from the boundary between provinces mountains
+	// are metamorphic & copper / gems are more likely
+	BoundaryRange uint
+
+	// land less than this high above sea level is sedimentary (unless
+	// the province is igneous)
+	BasinHeight float64
+
+	// number of each kind of deposit (best effort), gems are half as common
+	Deposits uint
+
+	// deposits of the same kind must be at least this far (in pixels) apart
+	DepositMinDist float64
+}
+
+// maskSettings shapes where land is (see applyMask). Land is drawn from the
+// Image & Shapes if given, else from the Preset.
+type maskSettings struct {
+	// a built in outline of where we want land (MaskNone to leave the land be)
+	Preset MaskPreset
+
+	// an image (scaled to fit the map) where white is land & black is sea.
+	// Nb. this is not saved with the landscape (see Save)
+	Image image.Image `json:"-"`
+
+	// polygons (in pixels) within which we want land
+	Shapes []*shapes.Polygon
+
+	// how far (in pixels) either side of the edge of the mask land fades
+	// into sea, the coast is somewhere in this band
+	Falloff uint
+
+	// 0-1 how strongly the mask shapes the heightmap, where 1 means there is
+	// no land outside of the mask (after the falloff) & no sea within it
+	Strength float64
+}
+
 type landSettings struct {
+	// how the heightmap is generated (HeightmapPerlin or HeightmapTectonic)
+	Mode HeightmapMode
+
 	// base height variance, higher numbers makes everything more chaotic
 	HeightVariance float64
 
 	// mountains are added to the above heightmap, higher numbes -> chaos
 	MountainVariance float64
+
+	// -- HeightmapTectonic only settings --
+	// number of tectonic plates
+	Plates uint
+
+	// 0-1 chance a plate is oceanic (below sea level) rather than continental
+	OceanicPlates float64
+
+	// how far (in pixels) either side of a plate boundary mountains, rifts
+	// etc. extend
+	BoundaryWidth uint
+
+	// max distance (in pixels) plate boundaries are pushed about by noise
+	BoundaryWarp uint
+
+	// height added where plates collide head on (mountains & volcanic arcs)
+	UpliftHeight float64
+
+	// depth of rifts where plates pull apart & trenches where one plate
+	// sinks beneath another
+	RiftDepth float64
+
+	// 0-1 how much of the heightmap is noise (HeightVariance &
+	// MountainVariance), rather than
+	// determined by plates
+	NoiseWeight float64
 }
 
 type biomeSettings struct {
+	// how we decide biomes; ClassifierDefault uses the thresholds below,
+	// ClassifierWhittaker & ClassifierKoppen use our climate
+	Classifier BiomeClassifier
+
+	// user defined biomes (ClassifierRules only), see LoadBiomeRules
+	Rules []*BiomeRule
+
 	// any temperature at or below this is auto frozen unless sea or volcanic
 	FrozenTemp uint8
 
@@ -158,13 +425,20 @@ type biomeSettings struct {
 
 	// hight over which we call the highlands biome
 	HighlandsHeight uint
+
+	// how far (in pixels) we look about each pixel when blending
+	// biomes together, 0 disables blending
+	EcotoneRadius uint
 }
 
 func DefaultConfig() *Config {
 	return &Config{
+		Seed:   time.Now().UnixNano(),
 		Width:  1000,
 		Height: 1000,
+		Scale:  defaultScale(),
 		Biome: &biomeSettings{
+			Classifier:          ClassifierDefault,
 			FrozenTemp:          70,
 			DesertTemp:          20,
 			DesertRain:          40,
@@ -176,6 +450,7 @@ func DefaultConfig() *Config {
 			FreshWaterRadius:    10,
 			MountainHeight:      210,
 			HighlandsHeight:     170,
+			EcotoneRadius:       5,
 		},
 		Lakes: &lakeSettings{
 			Variance:         0.23,
@@ -188,6 +463,12 @@ func DefaultConfig() *Config {
 		},
 		Rain: &rainfallSettings{
 			RainfallVariance: 0.03,
+			Wind:             WindBands,
+			WindHeading:      shapes.EAST,
+			Evaporation:      0.05,
+			Precipitation:    0.001,
+			Orographic:       0.01,
+			NoiseWeight:      0.3,
 		},
 		Temp: &tempSettings{
 			EquatorAverageTemp: 140,  // 40c
@@ -196,18 +477,65 @@ func DefaultConfig() *Config {
 			Variance:           0.03,
 		},
 		Rivers: &riverSettings{
+			Mode:                    RiverWalk,
 			Number:                  50,
 			OriginMinDist:           70,
 			ForceNorthSouthSections: true,
 			TurnChance:              0.4,
+			FlowThreshold:           1500,
+			FlowWidth:               1,
 		},
 		Land: &landSettings{
+			Mode:             HeightmapPerlin,
 			HeightVariance:   0.03, // base heightmap
 			MountainVariance: 0.10, // extra roughness
+			Plates:           12,
+			OceanicPlates:    0.6,
+			BoundaryWidth:    60,
+			BoundaryWarp:     60,
+			UpliftHeight:     150,
+			RiftDepth:        40,
+			NoiseWeight:      0.3,
+		},
+		Mask: &maskSettings{
+			Preset:   MaskNone,
+			Shapes:   []*shapes.Polygon{},
+			Falloff:  30,
+			Strength: 1,
+		},
+		Erosion: &erosionSettings{
+			Iterations:  200000,
+			MaxLifetime: 30,
+			Inertia:     0.05,
+			Capacity:    4,
+			MinSlope:    0.01,
+			Deposition:  0.3,
+			Erosion:     0.3,
+			Evaporation: 0.01,
+			Gravity:     4,
+			Radius:      3,
+		},
+		Thermal: &thermalSettings{
+			Iterations: 10,
+			Talus:      3,
+			Rate:       0.5,
 		},
 		Sea: &seaSettings{
 			SeaLevel: 115,
 		},
+		Ocean: &oceanSettings{
+			Currents:          true,
+			CurrentTemp:       6,
+			CurrentRange:      30,
+			CoastalModeration: 0.5,
+			CoastalRange:      40,
+		},
+		Seasons: &seasonSettings{
+			AxialTilt:        earthTilt,
+			TempRange:        20,
+			Monsoon:          0.8,
+			ContinentalRange: 150,
+		},
 		Volcanic: &volcSettings{
 			Variance:       0.6,
 			LavaRadius:     18,
@@ -216,6 +544,31 @@ func DefaultConfig() *Config {
 			Number:         5,
 			MaxRadius:      60,
 		},
+		Vegetation: &vegetationSettings{
+			Rainfall:     1500,
+			FreshWater:   0.5,
+			MinTemp:      -10,
+			OptimalTemp:  15,
+			MaxSlope:     1,
+			TreeLine:     3500,
+			TreeLineFade: 1500,
+		},
+		Soil: &soilSettings{
+			FloodplainRange:  4,
+			FloodplainHeight: 3,
+			VolcanicRange:    10,
+			RockySlope:       0.6,
+			FrozenTemp:       -8,
+			DryRain:          250,
+			LeachingRain:     2000,
+		},
+		Geology: &geologySettings{
+			Provinces:      20,
+			BoundaryRange:  20,
+			BasinHeight:    15,
+			Deposits:       10,
+			DepositMinDist: 30,
+		},
 		Swamp: &swampSettings{
 			Number:    25,
 			MaxHeight: 185,
@@ -0,0 +1,200 @@
+package landscape
+
+import (
+	"sort"
+)
+
+// BiomeWeight is how much of a biome makes up the area about a pixel
+type BiomeWeight struct {
+	Biome Biome
+
+	// 0-1, the weights of all biomes about a pixel sum to 1
+	Weight float64
+}
+
+// determineEcotones looks at the mix of biomes within `radius` pixels of
+// each pixel & returns the most common biome about each pixel other than the
+// pixel's own (or it's own biome if there are no others), how much (0-255)
+// of the area about each pixel is the pixel's own biome & the distance (in
+// pixels, max 255) to the nearest pixel of another biome. These allow
+// renderers to blend biomes rather than drawing hard edges.
+// If the map wraps east-west, so does the area we look at about each pixel.
+func determineEcotones(t *tracker, biomes *MapImage, radius uint) (*MapImage, *MapImage, *MapImage, error) {
+	x, y := biomes.Dimensions()
+	secondary := newPalettedMapImage(x, y, biomes.palette)
+	confidence := NewMapImage(x, y)
+	edge := NewMapImage(x, y)
+	edge.SetBackground(255)
+
+	// the best other biome (and it's count) seen about each pixel
+	best := make([]int, x*y)
+	copy(secondary.pix, biomes.pix)
+
+	present := map[uint8]bool{}
+	for _, v := range biomes.pix {
+		present[v] = true
+	}
+	ids := []uint8{}
+	for v := range present {
+		ids = append(ids, v)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// for each biome, count it about every pixel using a summed area table.
+	// If we wrap the table is padded with `pad` columns from the other side of
+	// the map on either side, so windows needn't stop at the edge
+	r := int(radius)
+	pad := 0
+	if biomes.wrapX {
+		r = minInt(r, (x-1)/2) // a window can't be wider than the map
+		pad = r
+	}
+	w := x + 2*pad
+	sums := make([]int, (w+1)*(y+1))
+	for n, id := range ids {
+		err := t.Progress(float64(n) / float64(len(ids)+1))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for dy := 0; dy < y; dy++ {
+			row := 0
+			for c := 0; c < w; c++ {
+				if biomes.pix[dy*x+biomes.wrap(c-pad)] == id {
+					row++
+				}
+				sums[(dy+1)*(w+1)+c+1] = sums[dy*(w+1)+c+1] + row
+			}
+		}
+
+		for dy := 0; dy < y; dy++ {
+			y0, y1 := maxInt(dy-r, 0), minInt(dy+r+1, y)
+			for dx := 0; dx < x; dx++ {
+				x0, x1 := maxInt(dx-r, 0), minInt(dx+r+1, x)
+				if biomes.wrapX {
+					x0, x1 = dx, dx+2*r+1 // nb. columns of the padded table
+				}
+				count := sums[y1*(w+1)+x1] - sums[y0*(w+1)+x1] - sums[y1*(w+1)+x0] + sums[y0*(w+1)+x0]
+
+				i := dy*x + dx
+				if biomes.pix[i] == id {
+					area := (x1 - x0) * (y1 - y0)
+					confidence.pix[i] = uint8(255 * count / area)
+				} else if count > best[i] {
+					best[i] = count
+					secondary.pix[i] = id
+				}
+			}
+		}
+	}
+
+	// distance to the nearest other biome, starting from pixels on a border
+	queue := []int{}
+	for i, v := range biomes.pix {
+		for _, d := range d8 {
+			j, ok := biomes.neighbour(i, d)
+			if !ok || biomes.pix[j] == v {
+				continue
+			}
+			edge.pix[i] = 1
+			queue = append(queue, i)
+			break
+		}
+	}
+	for n := 0; n < len(queue); n++ {
+		i := queue[n]
+		if edge.pix[i] == 255 {
+			continue
+		}
+		for _, d := range d8 {
+			j, ok := biomes.neighbour(i, d)
+			if !ok || edge.pix[j] <= edge.pix[i]+1 {
+				continue
+			}
+			edge.pix[j] = edge.pix[i] + 1
+			queue = append(queue, j)
+		}
+	}
+
+	return secondary, confidence, edge, nil
+}
+
+// BiomeMix returns the (up to) n most common biomes within the configured
+// ecotone radius of x,y, most common first. Weights are normalised over
+// all biomes seen, so those returned sum to 1 only if n covers them all.
+// The area wraps east-west if the map does. Returns nil if x,y is off the map.
+func (l *Landscape) BiomeMix(x, y, n int) []*BiomeWeight {
+	maxx, maxy := l.Dimensions()
+	if x < 0 || y < 0 || x >= maxx || y >= maxy || n < 1 {
+		return nil
+	}
+
+	r := 0
+	if l.config != nil && l.config.Biome != nil {
+		r = int(l.config.Biome.EcotoneRadius)
+	}
+
+	// nb. if we wrap Value takes care of pixels past the east-west edge
+	x0, x1 := maxInt(x-r, 0), minInt(x+r+1, maxx)
+	if l.biomes.wrapX {
+		r = minInt(r, (maxx-1)/2)
+		x0, x1 = x-r, x+r+1
+	}
+
+	counts := map[uint8]int{}
+	total := 0
+	for dy := maxInt(y-r, 0); dy < minInt(y+r+1, maxy); dy++ {
+		for dx := x0; dx < x1; dx++ {
+			counts[l.biomes.Value(dx, dy)]++
+			total++
+		}
+	}
+
+	ids := []uint8{}
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if counts[ids[i]] == counts[ids[j]] {
+			return ids[i] < ids[j]
+		}
+		return counts[ids[i]] > counts[ids[j]]
+	})
+
+	table := l.biomeIDs()
+	out := []*BiomeWeight{}
+	for _, id := range ids {
+		if len(out) >= n {
+			break
+		}
+		out = append(out, &BiomeWeight{Biome: table.biome(id), Weight: float64(counts[id]) / float64(total)})
+	}
+	return out
+}
+
+// SecondaryBiomeAt returns the most common biome about x,y other than it's
+// own, or it's own biome if there is no other nearby
+func (l *Landscape) SecondaryBiomeAt(x, y int) Biome {
+	if l.biomeSecondary == nil {
+		return l.biomeAt(x, y)
+	}
+	return l.biomeIDs().biome(l.biomeSecondary.Value(x, y))
+}
+
+// BiomeConfidenceAt returns 0-1 how much of the area about x,y is the same
+// biome as x,y. Low values are places where biomes blend together.
+func (l *Landscape) BiomeConfidenceAt(x, y int) float64 {
+	if l.biomeConfidence == nil {
+		return 1
+	}
+	return float64(l.biomeConfidence.Value(x, y)) / 255
+}
+
+// BiomeEdgeAt returns the distance in pixels from x,y to the nearest pixel of
+// a different biome, capped at 255
+func (l *Landscape) BiomeEdgeAt(x, y int) int {
+	if l.biomeEdge == nil {
+		return 255
+	}
+	return int(l.biomeEdge.Value(x, y))
+}
@@ -0,0 +1,125 @@
+package landscape
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetermineEcotones(t *testing.T) {
+	ids := builtinBiomeTable
+	biomes := newPalettedMapImage(20, 5, ids.palette)
+	for dx := 0; dx < 20; dx++ {
+		for dy := 0; dy < 5; dy++ {
+			if dx < 10 {
+				biomes.SetValue(dx, dy, ids.id(Grassland))
+			} else {
+				biomes.SetValue(dx, dy, ids.id(ForestTemperate))
+			}
+		}
+	}
+
+	tr := newReporter(context.Background(), nil).stage(StageEcotones)
+	secondary, confidence, edge, err := determineEcotones(tr, biomes, 2)
+	assert.Nil(t, err)
+
+	// far from the border there is only one biome
+	assert.Equal(t, ids.id(Grassland), secondary.Value(0, 2))
+	assert.Equal(t, uint8(255), confidence.Value(0, 2))
+	assert.Equal(t, uint8(10), edge.Value(0, 2))
+
+	// near the border we blend
+	assert.Equal(t, ids.id(ForestTemperate), secondary.Value(9, 2))
+	assert.Equal(t, ids.id(Grassland), secondary.Value(10, 2))
+	assert.Equal(t, uint8(255*3/5), confidence.Value(9, 2))
+	assert.Equal(t, uint8(255*4/5), confidence.Value(8, 2))
+	assert.Equal(t, uint8(1), edge.Value(9, 2))
+	assert.Equal(t, uint8(1), edge.Value(10, 2))
+	assert.Equal(t, uint8(3), edge.Value(12, 2))
+
+	// with no other biome about, everything is as far as it can be
+	_, _, edge, err = determineEcotones(tr, newPalettedMapImage(5, 5, ids.palette), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(255), edge.Value(2, 2))
+}
+
+func TestBiomeMix(t *testing.T) {
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	x, y := l.Dimensions()
+	for dx := 0; dx < x; dx += 3 {
+		for dy := 0; dy < y; dy += 3 {
+			mix := l.BiomeMix(dx, dy, 10)
+			total := 0.0
+			found := false
+			for _, m := range mix {
+				total += m.Weight
+				found = found || m.Biome == l.At(dx, dy).Biome
+			}
+			assert.InDelta(t, 1, total, 1e-9)
+			assert.True(t, found)
+			assert.InDelta(t, l.BiomeConfidenceAt(dx, dy), weightOf(mix, l.At(dx, dy).Biome), 1/255.0)
+
+			if l.BiomeEdgeAt(dx, dy) > int(l.config.Biome.EcotoneRadius) {
+				assert.Equal(t, 1, len(mix))
+				assert.Equal(t, l.At(dx, dy).Biome, l.SecondaryBiomeAt(dx, dy))
+			} else {
+				// the secondary biome is the most common other biome
+				assert.True(t, len(mix) > 1)
+				other := mix[0].Biome
+				if other == l.At(dx, dy).Biome {
+					other = mix[1].Biome
+				}
+				assert.Equal(t, other, l.SecondaryBiomeAt(dx, dy))
+			}
+		}
+	}
+	assert.Nil(t, l.BiomeMix(-1, 0, 3))
+}
+
+func weightOf(mix []*BiomeWeight, b Biome) float64 {
+	for _, m := range mix {
+		if m.Biome == b {
+			return m.Weight
+		}
+	}
+	return 0
+}
+
+func TestDetermineEcotonesWrapX(t *testing.T) {
+	ids := builtinBiomeTable
+	biomes := newPalettedMapImage(20, 5, ids.palette)
+	biomes.wrapX = true
+	for dx := 0; dx < 20; dx++ {
+		for dy := 0; dy < 5; dy++ {
+			if dx < 10 {
+				biomes.SetValue(dx, dy, ids.id(Grassland))
+			} else {
+				biomes.SetValue(dx, dy, ids.id(ForestTemperate))
+			}
+		}
+	}
+
+	tr := newReporter(context.Background(), nil).stage(StageEcotones)
+	secondary, confidence, edge, err := determineEcotones(tr, biomes, 2)
+	assert.Nil(t, err)
+
+	// the border at the edge of the map blends like the one in the middle
+	assert.Equal(t, ids.id(ForestTemperate), secondary.Value(0, 2))
+	assert.Equal(t, ids.id(Grassland), secondary.Value(19, 2))
+	assert.Equal(t, uint8(255*3/5), confidence.Value(0, 2))
+	assert.Equal(t, uint8(255*3/5), confidence.Value(19, 2))
+	assert.Equal(t, uint8(255*4/5), confidence.Value(1, 2))
+	assert.Equal(t, uint8(255*3/5), confidence.Value(9, 2))
+	assert.Equal(t, uint8(1), edge.Value(0, 2))
+
+	l := &Landscape{biomes: biomes, config: DefaultConfig()}
+	l.config.Biome.EcotoneRadius = 2
+	mix := l.BiomeMix(0, 2, 2)
+	assert.Equal(t, 2, len(mix))
+	assert.Equal(t, Grassland, mix[0].Biome)
+	assert.InDelta(t, 3/5.0, mix[0].Weight, 1e-9)
+	assert.Equal(t, ForestTemperate, mix[1].Biome)
+}
@@ -0,0 +1,300 @@
+package landscape
+
+import (
+	"math"
+	"math/rand"
+)
+
+// heightField is a float copy of a heightmap (0-1) that we can make small
+// changes to without losing them to rounding
+type heightField struct {
+	x int
+	y int
+	h []float64
+
+	// the east & west edges of the field join up
+	wrapX bool
+}
+
+func newHeightField(hmap *MapImage) *heightField {
+	x, y := hmap.Dimensions()
+	f := &heightField{x: x, y: y, h: make([]float64, x*y), wrapX: hmap.wrapX}
+	for dy := 0; dy < y; dy++ {
+		for dx := 0; dx < x; dx++ {
+			f.h[dy*x+dx] = hmap.Float(dx, dy) / 255
+		}
+	}
+	return f
+}
+
+// apply writes our heights back to the heightmap
+func (f *heightField) apply(hmap *MapImage) {
+	for dy := 0; dy < f.y; dy++ {
+		for dx := 0; dx < f.x; dx++ {
+			hmap.SetFloat(dx, dy, f.h[dy*f.x+dx]*255)
+		}
+	}
+}
+
+// index returns the index of ix, iy or false if it's off the map
+func (f *heightField) index(ix, iy int) (int, bool) {
+	if f.wrapX {
+		ix = wrapCoord(ix, f.x)
+	}
+	if ix < 0 || iy < 0 || ix >= f.x || iy >= f.y {
+		return 0, false
+	}
+	return iy*f.x + ix, true
+}
+
+// corners returns the indexes of the pixel cx, cy & it's neighbours to the
+// east, south & south east
+func (f *heightField) corners(cx, cy int) (int, int, int, int) {
+	i := cy*f.x + cx
+	if f.wrapX && cx == f.x-1 {
+		return i, i + 1 - f.x, i + f.x, i + 1
+	}
+	return i, i + 1, i + f.x, i + f.x + 1
+}
+
+// gradient returns the (bilinear interpolated) height & gradient at px, py.
+// Callers must ensure px, py are at least one pixel from the bottom edge (and
+// the right edge, unless we wrap east-west).
+func (f *heightField) gradient(px, py float64) (float64, float64, float64) {
+	cx := int(px)
+	cy := int(py)
+	u := px - float64(cx)
+	v := py - float64(cy)
+
+	a, b, c, d := f.corners(cx, cy)
+	nw := f.h[a]
+	ne := f.h[b]
+	sw := f.h[c]
+	se := f.h[d]
+
+	gx := (ne-nw)*(1-v) + (se-sw)*v
+	gy := (sw-nw)*(1-u) + (se-ne)*u
+	h := nw*(1-u)*(1-v) + ne*u*(1-v) + sw*(1-u)*v + se*u*v
+
+	return h, gx, gy
+}
+
+// determineErosion runs a droplet based hydraulic erosion simulation over the heightmap.
+// Each droplet is dropped at a random place, rolls downhill picking up sediment
+// while it is moving quickly (eroding) and dropping it as it slows, fills a pit or
+// evaporates (depositing). The effect is to carve valleys & build up flat plains
+// at the foot of slopes.
+//
+// Based on "Implementation of a method for hydraulic erosion" by Hans Theobald Beyer.
+func determineErosion(t *tracker, rng *rand.Rand, hmap *MapImage, cfg *erosionSettings) error {
+	if cfg.Iterations < 1 {
+		return nil
+	}
+
+	f := newHeightField(hmap)
+	if f.x < 2 || f.y < 2 {
+		return nil
+	}
+
+	radius := int(cfg.Radius)
+	if radius < 1 {
+		radius = 1
+	}
+
+	// droplets may roll over the east-west edge if we wrap
+	width := float64(f.x - 1)
+	if f.wrapX {
+		width = float64(f.x)
+	}
+
+	for i := uint(0); i < cfg.Iterations; i++ {
+		if i%1000 == 0 {
+			err := t.Progress(float64(i) / float64(cfg.Iterations))
+			if err != nil {
+				return err
+			}
+		}
+
+		px := rng.Float64() * width
+		py := rng.Float64() * float64(f.y-1)
+		dirX := 0.0
+		dirY := 0.0
+		speed := 1.0
+		water := 1.0
+		sediment := 0.0
+
+		for life := uint(0); life < cfg.MaxLifetime; life++ {
+			cx := int(px)
+			cy := int(py)
+			u := px - float64(cx)
+			v := py - float64(cy)
+
+			h, gx, gy := f.gradient(px, py)
+
+			// roll downhill, keeping some of our previous direction
+			dirX = dirX*cfg.Inertia - gx*(1-cfg.Inertia)
+			dirY = dirY*cfg.Inertia - gy*(1-cfg.Inertia)
+			l := math.Sqrt(dirX*dirX + dirY*dirY)
+			if l == 0 {
+				break // perfectly flat, we're not going anywhere
+			}
+			dirX /= l
+			dirY /= l
+			px += dirX
+			py += dirY
+			if f.wrapX {
+				px = math.Mod(px+width, width)
+			}
+
+			if px < 0 || py < 0 || px >= width || py >= float64(f.y-1) {
+				break // we've rolled off the map
+			}
+
+			newH, _, _ := f.gradient(px, py)
+			dh := newH - h
+
+			// how much sediment we could be carrying
+			capacity := math.Max(-dh, cfg.MinSlope) * speed * water * cfg.Capacity
+
+			if sediment > capacity || dh > 0 {
+				// we're going uphill (fill the pit we're in) or carrying too much,
+				// drop sediment at our old position
+				amount := (sediment - capacity) * cfg.Deposition
+				if dh > 0 {
+					amount = math.Min(dh, sediment)
+				}
+				sediment -= amount
+
+				nw, ne, sw, se := f.corners(cx, cy)
+				f.h[nw] += amount * (1 - u) * (1 - v)
+				f.h[ne] += amount * u * (1 - v)
+				f.h[sw] += amount * (1 - u) * v
+				f.h[se] += amount * u * v
+			} else {
+				// erode, spreading the effect over an area about our old position
+				amount := math.Min((capacity-sediment)*cfg.Erosion, -dh)
+				sediment += f.erode(cx, cy, radius, amount)
+			}
+
+			speed = math.Sqrt(math.Max(0, speed*speed-dh*cfg.Gravity))
+			water *= 1 - cfg.Evaporation
+		}
+	}
+
+	f.apply(hmap)
+	return nil
+}
+
+// erode removes up to `amount` from the area within `radius` of cx, cy weighted
+// by distance from the centre & returns how much was actually removed
+func (f *heightField) erode(cx, cy, radius int, amount float64) float64 {
+	type cell struct {
+		i int
+		w float64
+	}
+
+	cells := []cell{}
+	sum := 0.0
+	for iy := cy - radius; iy <= cy+radius; iy++ {
+		for ix := cx - radius; ix <= cx+radius; ix++ {
+			i, ok := f.index(ix, iy)
+			if !ok {
+				continue
+			}
+			w := float64(radius) - math.Sqrt(float64((ix-cx)*(ix-cx)+(iy-cy)*(iy-cy)))
+			if w <= 0 {
+				continue
+			}
+			cells = append(cells, cell{i, w})
+			sum += w
+		}
+	}
+
+	removed := 0.0
+	for _, c := range cells {
+		delta := math.Min(f.h[c.i], amount*c.w/sum)
+		f.h[c.i] -= delta
+		removed += delta
+	}
+
+	return removed
+}
+
+// determineThermalErosion moves material from any pixel that is more than
+// the talus height above it's neighbours down onto those neighbours, as rock
+// would crumble & slide down a slope that's too steep.
+// This removes lone spikes & harsh cliffs from the heightmap.
+func determineThermalErosion(t *tracker, hmap *MapImage, cfg *thermalSettings) error {
+	if cfg.Iterations < 1 {
+		return nil
+	}
+
+	f := newHeightField(hmap)
+	talus := cfg.Talus / 255
+	delta := make([]float64, len(f.h))
+
+	for i := uint(0); i < cfg.Iterations; i++ {
+		err := t.Progress(float64(i) / float64(cfg.Iterations))
+		if err != nil {
+			return err
+		}
+
+		for j := range delta {
+			delta[j] = 0
+		}
+
+		for dy := 0; dy < f.y; dy++ {
+			for dx := 0; dx < f.x; dx++ {
+				me := dy*f.x + dx
+				h := f.h[me]
+
+				// figure out how far over the talus each lower neighbour is
+				total := 0.0
+				highest := 0.0
+				for iy := dy - 1; iy <= dy+1; iy++ {
+					for ix := dx - 1; ix <= dx+1; ix++ {
+						n, ok := f.index(ix, iy)
+						if !ok || (ix == dx && iy == dy) {
+							continue
+						}
+						diff := h - f.h[n]
+						if diff <= talus {
+							continue
+						}
+						total += diff
+						if diff > highest {
+							highest = diff
+						}
+					}
+				}
+				if total == 0 {
+					continue
+				}
+
+				// move material to each, in proportion to how much lower they are
+				move := cfg.Rate * (highest - talus) / 2
+				for iy := dy - 1; iy <= dy+1; iy++ {
+					for ix := dx - 1; ix <= dx+1; ix++ {
+						n, ok := f.index(ix, iy)
+						if !ok || (ix == dx && iy == dy) {
+							continue
+						}
+						diff := h - f.h[n]
+						if diff <= talus {
+							continue
+						}
+						delta[n] += move * diff / total
+					}
+				}
+				delta[me] -= move
+			}
+		}
+
+		for j := range delta {
+			f.h[j] += delta[j]
+		}
+	}
+
+	f.apply(hmap)
+	return nil
+}
@@ -0,0 +1,69 @@
+package landscape
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetermineErosion(t *testing.T) {
+	cfg := testConfig()
+	tr := newReporter(context.Background(), nil).stage(StageErosion)
+
+	hmap := noise(rand.New(rand.NewSource(1)), 100, 100, 0.1, false)
+	before := mutateImage(hmap, func(_, _ int, c uint8) uint8 { return c })
+
+	err := determineErosion(tr, rand.New(rand.NewSource(1)), hmap, cfg.Erosion)
+	assert.Nil(t, err)
+
+	changed := 0
+	raised := 0
+	eachPixel(hmap, func(dx, dy int, c uint8) {
+		b := before.Value(dx, dy)
+		if c != b {
+			changed++
+		}
+		if c > b {
+			raised++
+		}
+	})
+
+	// material is both removed & deposited
+	assert.True(t, changed > 0)
+	assert.True(t, raised > 0)
+	assert.True(t, raised < changed)
+}
+
+func TestDetermineErosionDisabled(t *testing.T) {
+	cfg := testConfig()
+	cfg.Erosion.Iterations = 0
+	tr := newReporter(context.Background(), nil).stage(StageErosion)
+
+	hmap := noise(rand.New(rand.NewSource(1)), 100, 100, 0.1, false)
+	before := mutateImage(hmap, func(_, _ int, c uint8) uint8 { return c })
+
+	err := determineErosion(tr, rand.New(rand.NewSource(1)), hmap, cfg.Erosion)
+	assert.Nil(t, err)
+
+	assertSameImage(t, "height", before, hmap)
+}
+
+func TestDetermineThermalErosion(t *testing.T) {
+	cfg := testConfig()
+	tr := newReporter(context.Background(), nil).stage(StageThermal)
+
+	hmap := NewMapImage(20, 20)
+	hmap.SetBackground(100)
+	hmap.SetValue(10, 10, 250) // a lone spike
+
+	err := determineThermalErosion(tr, hmap, cfg.Thermal)
+	assert.Nil(t, err)
+
+	// the spike is worn down onto it's neighbours & nothing is much
+	// steeper than the talus
+	assert.True(t, hmap.Value(10, 10) < 200)
+	assert.True(t, hmap.Value(9, 10) > 100)
+	assert.True(t, float64(hmap.Value(10, 10)-hmap.Value(9, 10)) < 4*cfg.Thermal.Talus)
+}
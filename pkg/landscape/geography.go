@@ -1,7 +1,7 @@
 package landscape
 
 import (
-	perlin "github.com/voidshard/cartographer/pkg/perlin"
+	"math/rand"
 )
 
 // findMountains discovers all mountains (over some height).
@@ -23,7 +23,7 @@ func findMountains(hmap *MapImage) []*POI {
 // Nb. we probably should figure out areas based on 'drainage' eg. above sea level, near water,
 // reasonably flat / bowl shaped ..
 // Currently areas are "swamp" or not
-func determineSwamp(hmap, rivers, sea *MapImage, ss *swampSettings, riverends []*POI) (*MapImage, []*POI) {
+func determineSwamp(t *tracker, rng *rand.Rand, hmap, rivers, sea *MapImage, ss *swampSettings, riverends []*POI) (*MapImage, []*POI, error) {
 	if ss.Radius < 1 {
 		ss.Radius = 1
 	}
@@ -35,16 +35,21 @@ func determineSwamp(hmap, rivers, sea *MapImage, ss *swampSettings, riverends []
 	pois := []*POI{}
 
 	if len(riverends) == 0 {
-		return smap, pois
+		return smap, pois, nil
 	}
 
-	per := &MapImage{im: perlin.Perlin(x, y, ss.Variance)}
+	per := noise(rng, x, y, ss.Variance, hmap.wrapX)
 
-	for _, start := range riverends {
+	for i, start := range riverends {
 		if uint(len(pois)) >= ss.Number {
 			break
 		}
 
+		err := t.Progress(float64(i) / float64(len(riverends)))
+		if err != nil {
+			return nil, nil, err
+		}
+
 		oh := hmap.Value(start.X, start.Y)
 		if oh >= ss.MaxHeight {
 			continue
@@ -109,10 +114,10 @@ func determineSwamp(hmap, rivers, sea *MapImage, ss *swampSettings, riverends []
 		}
 	}
 
-	return smap, pois
+	return smap, pois, nil
 }
 
-func determineGeothermal(hmap *MapImage, sealevel uint8, vs *volcSettings) (*MapImage, *MapImage, []*POI) {
+func determineGeothermal(t *tracker, rng *rand.Rand, hmap, tect *MapImage, sealevel uint8, vs *volcSettings) (*MapImage, *MapImage, []*POI, error) {
 	x, y := hmap.Dimensions()
 
 	// new blank map
@@ -120,18 +125,22 @@ func determineGeothermal(hmap *MapImage, sealevel uint8, vs *volcSettings) (*Map
 	vmap.SetBackground(0)
 	pois := []*POI{}
 
-	temp := NewMapImage(x, y)
-	temp.SetBackground(0)
+	temp := NewMapImage16(x, y)
 
 	// pick some places to place volcanoes
-	origins := geothermalOrigins(hmap, vs)
+	origins := geothermalOrigins(rng, hmap, tect, vs)
 	if len(origins) == 0 {
-		return vmap, temp, pois
+		return vmap, temp, pois, nil
 	}
 
-	pmap := &MapImage{im: perlin.Perlin(x, y, vs.Variance)}
+	pmap := noise(rng, x, y, vs.Variance, hmap.wrapX)
+
+	for i, volcano := range origins {
+		err := t.Progress(float64(i) / float64(len(origins)))
+		if err != nil {
+			return nil, nil, nil, err
+		}
 
-	for _, volcano := range origins {
 		pois = append(pois, &POI{X: volcano.X(), Y: volcano.Y(), Type: Volcano})
 
 		pv := pmap.Value(volcano.X(), volcano.Y())
@@ -151,19 +160,19 @@ func determineGeothermal(hmap *MapImage, sealevel uint8, vs *volcSettings) (*Map
 
 			me := check[len(check)-1]
 
-			dist := volcano.Point.DistPt(me.Point)
+			dist := hmap.dist(volcano.Point, me.Point)
 
 			if me.V > lvMax || me.V < lvMin || dist >= vs.MaxRadius/2 { // VOLCANIC
-				hv := hmap.Value(me.X(), me.Y())
-				if hv < sealevel {
+				hv := hmap.Float(me.X(), me.Y())
+				if hv < float64(sealevel) {
 					// raise up land above sealevel
-					notSealevel := 255 - sealevel
-					hv = uint8(float64(hv)*float64(notSealevel)/255) + sealevel
+					notSealevel := 255 - float64(sealevel)
+					hv = hv*notSealevel/255 + float64(sealevel)
 				}
-				hmap.SetValue(me.X(), me.Y(), hv)
+				hmap.SetFloat(me.X(), me.Y(), hv)
 				vmap.SetValue(me.X(), me.Y(), 120)
 			} else { // LAVA
-				hmap.SetValue(me.X(), me.Y(), decrement(hmap.Value(me.X(), me.Y()), 5))
+				hmap.SetFloat(me.X(), me.Y(), decrementFloat(hmap.Float(me.X(), me.Y()), 5))
 				vmap.SetValue(me.X(), me.Y(), 255)
 			}
 
@@ -186,7 +195,7 @@ func determineGeothermal(hmap *MapImage, sealevel uint8, vs *volcSettings) (*Map
 				}
 				seen[idx] = true
 
-				dist := volcano.Point.DistPt(next.Point)
+				dist := hmap.dist(volcano.Point, next.Point)
 				if dist > vs.MaxRadius { // too far from volc centre
 					continue
 				}
@@ -196,15 +205,36 @@ func determineGeothermal(hmap *MapImage, sealevel uint8, vs *volcSettings) (*Map
 		}
 	}
 
-	return vmap, temp, pois
+	return vmap, temp, pois, nil
 }
 
 // geothermalOrigins figures out where we can put volcanoes.
 // Note that we actually could put these at any height .. even if it ended
 // up at sealevel it could simply be a caldera with no volcanic cone.
 // Even beneath the sea wouldn't be strange
-func geothermalOrigins(hmap *MapImage, cfg *volcSettings) []*Pixel {
+// If we have tectonic plates, volcanoes form along the most active plate
+// boundaries (see determineTectonics) instead.
+func geothermalOrigins(rng *rand.Rand, hmap, tect *MapImage, cfg *volcSettings) []*Pixel {
+	var top uint8
+	eachPixel(tect, func(dx, dy int, c uint8) {
+		if c > top {
+			top = c
+		}
+	})
+	if top > 0 {
+		return origins(
+			rng,
+			tect,
+			cfg.OriginMinDist,
+			int(cfg.Number),
+			decrement(top, 40), // the most active boundaries (volcanic arcs)
+			255,
+			20, // but any boundary will do
+		)
+	}
+
 	return origins(
+		rng,
 		hmap,
 		cfg.OriginMinDist,
 		int(cfg.Number),
@@ -214,20 +244,40 @@ func geothermalOrigins(hmap *MapImage, cfg *volcSettings) []*Pixel {
 	)
 }
 
-// determineRainfall returns rainfall 0-255
-// TODO; include rain shadowing, consider prevailing winds
-func determineRainfall(hmap, rain *MapImage, rs *rainfallSettings) {
+// determineRainfall returns rainfall 0-255, as carried on the prevailing winds
+// (see windRainfall) with a bit of noise. Rain is added to the given map (which
+// already holds areas near fresh water).
+func determineRainfall(t *tracker, rng *rand.Rand, hmap, sea, rain *MapImage, sealevel uint8, rs *rainfallSettings) error {
 	x, y := hmap.Dimensions()
 
-	pmap := &MapImage{im: perlin.Perlin(x, y, rs.RainfallVariance)}
+	pmap := noise16(rng, x, y, rs.RainfallVariance, hmap.wrapX)
 
-	for dx := 0; dx < x; dx++ {
-		for dy := 0; dy < y; dy++ {
-			now := rain.Value(dx, dy)
-			now = increment(now, pmap.Value(dx, dy))
-			rain.SetValue(dx, dy, now)
+	if rs.Wind == WindNone {
+		for dx := 0; dx < x; dx++ {
+			err := t.Progress(float64(dx) / float64(x))
+			if err != nil {
+				return err
+			}
+			for dy := 0; dy < y; dy++ {
+				rain.SetFloat(dx, dy, rain.Float(dx, dy)+pmap.Float(dx, dy))
+			}
 		}
+		return nil
+	}
+
+	wind, err := windRainfall(t, hmap, sea, pmap, sealevel, rs)
+	if err != nil {
+		return err
 	}
+
+	for dy := 0; dy < y; dy++ {
+		for dx := 0; dx < x; dx++ {
+			v := wind[dy*x+dx]*(1-rs.NoiseWeight) + pmap.Float(dx, dy)*rs.NoiseWeight
+			rain.SetFloat(dx, dy, rain.Float(dx, dy)+v)
+		}
+	}
+
+	return nil
 }
 
 // determineTemp returns a map of average temperatures in degrees Celcius,
@@ -246,11 +296,11 @@ func determineRainfall(hmap, rain *MapImage, rs *rainfallSettings) {
 //
 // This means we should lose 1c in temp from sealevel as we climb every 2 pts
 // of height. Well, more like 3c per 5 points but .. whatever.
-func determineTemp(hm, out *MapImage, sealevel uint8, cfg *tempSettings) *MapImage {
+func determineTemp(t *tracker, rng *rand.Rand, hm, out *MapImage, sealevel uint8, cfg *tempSettings) error {
 	x, y := hm.Dimensions()
 	equator := y / 2
 
-	pmap := &MapImage{im: perlin.Perlin(x, y, cfg.Variance)}
+	pmap := noise16(rng, x, y, cfg.Variance, hm.wrapX)
 
 	// how wide the equator 'band' is
 	band := cfg.EquatorWidth * float64(y)
@@ -259,11 +309,13 @@ func determineTemp(hm, out *MapImage, sealevel uint8, cfg *tempSettings) *MapIma
 	dty := float64(cfg.EquatorAverageTemp-cfg.PoleAverageTemp) / ((float64(y) - band) / 2)
 
 	for dx := 0; dx < x; dx++ {
+		err := t.Progress(float64(dx) / float64(x))
+		if err != nil {
+			return err
+		}
 		for dy := 0; dy < y; dy++ {
-			temp := cfg.EquatorAverageTemp
-
 			// add temp for volcanic region
-			temp = increment(temp, out.Value(dx, dy))
+			temp := float64(cfg.EquatorAverageTemp) + out.Float(dx, dy)
 
 			dueToY := 0.0
 			if dy > equator {
@@ -272,36 +324,31 @@ func determineTemp(hm, out *MapImage, sealevel uint8, cfg *tempSettings) *MapIma
 				dueToY = float64(equator-dy) * 0.85
 			}
 
-			ret := temp
 			if dueToY > band {
 				// we're inside the equator
-				ret = decrement(temp, toUint8(dueToY*dty))
+				temp = decrementFloat(temp, dueToY*dty)
 			}
 
 			// add a bit of variation
-			pv := pmap.Value(dx, dy)
-			if pv < 125 {
-				ret = decrement(ret, (125-pv)/10)
-			} else {
-				ret = increment(ret, (pv-125)/10)
-			}
+			temp += (pmap.Float(dx, dy) - 125) / 10
 
-			out.SetValue(dx, dy, ret)
+			out.SetFloat(dx, dy, temp)
 		}
 	}
 
-	return out
+	return nil
 }
 
 // determineSea returns all areas that should be regarded as sea.
-// - Any pixel on the map edge beneath sea level is sea
+// - Any pixel on the map edge beneath sea level is sea.
 // - Any pixel adjacent to a sea pixel that is below sea level is also sea.
+// If the map wraps east-west only the north & south edges count as edges.
 // nb; this meas we can have areas of lowlands below sea level that are
 // not sea -- this is intentional & actually the case in some parts of
 // the world.
-func determineSea(hm *MapImage, cfg *seaSettings) *MapImage {
+func determineSea(t *tracker, hm *MapImage, cfg *seaSettings) (*MapImage, error) {
 	x, y := hm.Dimensions()
-	level := cfg.SeaLevel
+	level := float64(cfg.SeaLevel)
 	sea := NewMapImage(x, y)
 	sea.SetBackground(0)
 
@@ -310,35 +357,47 @@ func determineSea(hm *MapImage, cfg *seaSettings) *MapImage {
 
 	// go around the edges & find initial sea tiles
 	for dx := 0; dx < x; dx++ {
-		if hm.Value(dx, 0) <= level {
+		if hm.Float(dx, 0) <= level {
 			sea.SetValue(dx, 0, 255)
 			todo = append(todo, hm.Pixel(dx, 0))
 		}
-		if hm.Value(dx, y-1) <= level {
+		if hm.Float(dx, y-1) <= level {
 			sea.SetValue(dx, y-1, 255)
 			todo = append(todo, hm.Pixel(dx, y-1))
 		}
 	}
-	for dy := 0; dy < y; dy++ {
-		if hm.Value(0, dy) <= level {
+	for dy := 0; dy < y && !hm.wrapX; dy++ { // if we wrap there is no east / west edge
+		if hm.Float(0, dy) <= level {
 			sea.SetValue(0, dy, 255)
 			todo = append(todo, hm.Pixel(0, dy))
 		}
-		if hm.Value(x-1, dy) <= level {
+		if hm.Float(x-1, dy) <= level {
 			sea.SetValue(x-1, dy, 255)
 			todo = append(todo, hm.Pixel(x-1, dy))
 		}
 	}
 
 	// expand sea tiles into neighbouring tiles
+	// (we can't know how much sea there will be, but it can't be more than
+	// the whole map)
+	total := float64(x * y)
+	done := 0
 	for {
 		if len(todo) == 0 {
 			break
 		}
 
+		done++
+		if done%1024 == 0 {
+			err := t.Progress(float64(done) / total)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		p := todo[0]
 		for _, n := range hm.Nearby(p.X(), p.Y(), 1, false) {
-			if n.V > level {
+			if hm.Float(n.X(), n.Y()) > level {
 				continue
 			}
 
@@ -355,5 +414,5 @@ func determineSea(hm *MapImage, cfg *seaSettings) *MapImage {
 		todo = todo[:len(todo)-1]
 	}
 
-	return sea
+	return sea, nil
 }
@@ -0,0 +1,41 @@
+package landscape
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetermineSeaWrapX(t *testing.T) {
+	// low ground down the east & west edges, cut off from the north & south
+	// edges by land
+	hmap := NewMapImage(10, 10)
+	eachPixel(hmap, func(dx, dy int, _ uint8) {
+		if dy == 0 || dy == 9 || (dx > 1 && dx < 8) {
+			hmap.SetValue(dx, dy, 200)
+		} else {
+			hmap.SetValue(dx, dy, 50)
+		}
+	})
+	cfg := DefaultConfig().Sea
+	tr := newReporter(context.Background(), nil).stage(StageSea)
+
+	sea, err := determineSea(tr, hmap, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(255), sea.Value(0, 5))
+	assert.Equal(t, uint8(255), sea.Value(9, 5))
+
+	hmap.wrapX = true
+	sea, err = determineSea(tr, hmap, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0), sea.Value(0, 5))
+	assert.Equal(t, uint8(0), sea.Value(9, 5))
+
+	// sea reaching the west edge carries on over it
+	hmap.SetValue(0, 9, 50)
+	sea, err = determineSea(tr, hmap, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(255), sea.Value(0, 5))
+	assert.Equal(t, uint8(255), sea.Value(9, 5))
+}
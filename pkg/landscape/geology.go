@@ -0,0 +1,385 @@
+package landscape
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"golang.org/x/image/colornames"
+
+	"github.com/voidshard/cartographer/pkg/shapes"
+	"github.com/voidshard/cartographer/pkg/voronoi"
+)
+
+// Rock is the type of bedrock beneath an area
+type Rock string
+
+var (
+	RockNone        Rock = "none"
+	RockSedimentary Rock = "sedimentary" // laid down in layers by water (sandstone, limestone, shale)
+	RockIgneous     Rock = "igneous"     // cooled from magma (granite, basalt)
+	RockMetamorphic Rock = "metamorphic" // changed by heat & pressure (slate, marble, schist)
+
+	// rocks lists each rock type, a rock's ID (it's value in the geology
+	// layer) is it's index here
+	rocks = []Rock{
+		RockNone,
+		RockSedimentary,
+		RockIgneous,
+		RockMetamorphic,
+	}
+
+	// rockPalette holds the colour used to render each rock (by ID)
+	rockPalette = color.Palette{
+		colornames.Black,
+		colornames.Burlywood,
+		colornames.Indianred,
+		colornames.Slateblue,
+	}
+)
+
+// rockID returns the ID of the given rock in the geology layer
+func rockID(r Rock) uint8 {
+	for i, v := range rocks {
+		if v == r {
+			return uint8(i)
+		}
+	}
+	return 0
+}
+
+// toRock returns the rock with the given ID
+func toRock(id uint8) Rock {
+	if int(id) >= len(rocks) {
+		return RockNone
+	}
+	return rocks[id]
+}
+
+// RockAt returns the bedrock at x,y
+func (l *Landscape) RockAt(x, y int) Rock {
+	if l.geology == nil {
+		return RockNone
+	}
+	return toRock(l.geology.Value(x, y))
+}
+
+// voronoiCells splits an x by y map into `n` voronoi cells about random sites.
+// Returns the graph & which site (0 to n-1) each cell of the graph is about.
+// If wrapX is set the cells wrap east-west; each site is repeated a map width
+// to the west & east so that cells carry on over the edge of the map.
+func voronoiCells(rng *rand.Rand, x, y, n int, wrapX bool) (*voronoi.Graph, []int, error) {
+	if n < 1 {
+		return nil, nil, fmt.Errorf("too few voronoi cells %d, min 1", n)
+	}
+
+	bounds := shapes.NewPolygon([]*shapes.Point{
+		shapes.Pt(0, 0),
+		shapes.Pt(0, float64(y)),
+		shapes.Pt(float64(x), float64(y)),
+		shapes.Pt(float64(x), 0),
+	})
+	if wrapX {
+		// nb. the voronoi lib panics on many sites with the same Y, which our
+		// repeated sites always have, so we compute the graph on it's side
+		bounds = shapes.NewPolygon([]*shapes.Point{
+			shapes.Pt(0, -float64(x)),
+			shapes.Pt(float64(y), -float64(x)),
+			shapes.Pt(float64(y), 2*float64(x)),
+			shapes.Pt(0, 2*float64(x)),
+		})
+	}
+
+	var graph *voronoi.Graph
+	var sites []*shapes.Point
+	var err error
+	for i := 0; i < 10; i++ {
+		sites = []*shapes.Point{}
+		for j := 0; j < n; j++ {
+			sites = append(sites, shapes.Pt(rng.Float64()*float64(x), rng.Float64()*float64(y)))
+		}
+		if wrapX {
+			for j := 0; j < n; j++ {
+				sites = append(sites, shapes.Pt(sites[j].X-float64(x), sites[j].Y))
+			}
+			for j := 0; j < n; j++ {
+				sites = append(sites, shapes.Pt(sites[j].X+float64(x), sites[j].Y))
+			}
+			for _, s := range sites {
+				s.X, s.Y = s.Y, s.X
+			}
+		}
+		// the voronoi lib can fail on odd arrangements of points, if so we try again
+		graph, err = voronoi.Compute(sites, bounds)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if wrapX {
+		transposeGraph(graph)
+		for _, s := range sites {
+			s.X, s.Y = s.Y, s.X
+		}
+	}
+
+	siteOf := make([]int, len(graph.Cells))
+	for i, c := range graph.Cells {
+		if !wrapX {
+			siteOf[i] = i
+			continue
+		}
+		// the lib may reorder cells (& rounds sites), so we find our site
+		best := math.Inf(1)
+		for j, s := range sites {
+			d := math.Hypot(c.Site.X-s.X, c.Site.Y-s.Y)
+			if d < best {
+				best = d
+				siteOf[i] = j % n
+			}
+		}
+	}
+
+	return graph, siteOf, nil
+}
+
+// transposeGraph swaps the X & Y of all points in the graph
+func transposeGraph(graph *voronoi.Graph) {
+	seen := map[*shapes.Point]bool{}
+	swap := func(pts ...*shapes.Point) {
+		for _, p := range pts {
+			if p == nil || seen[p] {
+				continue
+			}
+			seen[p] = true
+			p.X, p.Y = p.Y, p.X
+		}
+	}
+
+	swap(graph.Verts...)
+	swap(graph.Poly.Points...)
+	for _, e := range graph.Edges {
+		swap(e.PointA, e.PointB)
+	}
+	for _, c := range graph.Cells {
+		swap(c.Site)
+		if c.Bounds != nil {
+			swap(c.Bounds.Points...)
+		}
+		for _, h := range c.Edges {
+			h.Angle = math.Pi/2 - h.Angle
+		}
+	}
+}
+
+// nearestCell returns the index of the cell whose site is closest to px,py
+func nearestCell(graph *voronoi.Graph, px, py float64) int {
+	best := math.Inf(1)
+	nearest := 0
+	for j, c := range graph.Cells {
+		d := (c.Site.X-px)*(c.Site.X-px) + (c.Site.Y-py)*(c.Site.Y-py)
+		if d < best {
+			best = d
+			nearest = j
+		}
+	}
+	return nearest
+}
+
+// provinces splits the map into `n` voronoi cells & returns which cell
+// (0 to n-1) each pixel is in
+func provinces(rng *rand.Rand, x, y, n int, wrapX bool) (*voronoi.Graph, []int, error) {
+	graph, siteOf, err := voronoiCells(rng, x, y, n, wrapX)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// a pixel is in the cell of it's nearest site
+	cells := make([]int, x*y)
+	for i := range cells {
+		cells[i] = siteOf[nearestCell(graph, float64(i%x), float64(i/x))]
+	}
+
+	return graph, cells, nil
+}
+
+// determineGeology returns a map of the bedrock (by ID) of each pixel &
+// places mineral deposits. The map is split into provinces each with it's
+// own bedrock, where volcanic land is igneous, the roots of mountains near
+// province boundaries are metamorphic & basins are filled with sediment.
+func (l *Landscape) determineGeology(t *tracker, rng *rand.Rand, cfg *Config) (*MapImage, []*POI, error) {
+	gcfg := cfg.Geology
+	x, y := l.height.Dimensions()
+	out := newPalettedMapImage(x, y, rockPalette)
+
+	_, cells, err := provinces(rng, x, y, int(gcfg.Provinces), l.height.wrapX)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w for geology provinces", err)
+	}
+
+	// each province has a base rock type
+	base := map[int]Rock{}
+	for i := 0; i < int(gcfg.Provinces); i++ {
+		r := rng.Float64()
+		if r < 0.55 {
+			base[i] = RockSedimentary
+		} else if r < 0.8 {
+			base[i] = RockMetamorphic
+		} else {
+			base[i] = RockIgneous
+		}
+	}
+
+	// how far each pixel is from the boundary between provinces
+	boundary := NewMapImage(x, y)
+	boundary.wrapX = l.height.wrapX
+	for i, c := range cells {
+		for _, d := range d8 {
+			j, ok := boundary.neighbour(i, d)
+			if ok && cells[j] != c {
+				boundary.pix[i] = 255
+				break
+			}
+		}
+	}
+	brange := int(gcfg.BoundaryRange)
+	bdist, err := distanceFrom(t, boundary, brange, func(v uint8) bool { return v == 255 })
+	if err != nil {
+		return nil, nil, err
+	}
+	nearBoundary := func(i int) float64 {
+		if bdist[i] < 0 || brange == 0 {
+			return 0
+		}
+		return 1 - float64(bdist[i])/float64(brange+1)
+	}
+
+	sealevel := float64(cfg.Sea.SeaLevel)
+	for dx := 0; dx < x; dx++ {
+		err := t.Progress(float64(dx) / float64(x) / 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		for dy := 0; dy < y; dy++ {
+			i := dy*x + dx
+			h := l.height.Float(dx, dy)
+
+			r := base[cells[i]]
+			switch {
+			case l.volcanic.Value(dx, dy) > 0:
+				r = RockIgneous
+			case h >= float64(cfg.Biome.MountainHeight) && nearBoundary(i) > 0:
+				r = RockMetamorphic // folded up where provinces collide
+			case h < sealevel+gcfg.BasinHeight && r != RockIgneous:
+				r = RockSedimentary // low land collects sediment
+			}
+			out.SetValue(dx, dy, rockID(r))
+		}
+	}
+
+	// how likely each deposit is to form at a given pixel (0-1)
+	highland := float64(cfg.Biome.HighlandsHeight)
+	iron := map[Rock]float64{RockSedimentary: 0.5, RockIgneous: 0.8, RockMetamorphic: 0.6}
+	favour := map[PointType]func(dx, dy, i int, r Rock) float64{
+		IronDeposit: func(dx, dy, i int, r Rock) float64 {
+			return iron[r]
+		},
+		CopperDeposit: func(dx, dy, i int, r Rock) float64 {
+			near := math.Max(nearBoundary(i), float64(l.volcanic.Value(dx, dy))/255)
+			if r == RockIgneous {
+				return 0.3 + 0.7*near
+			}
+			return 0.3 * near
+		},
+		GoldDeposit: func(dx, dy, i int, r Rock) float64 {
+			if r != RockMetamorphic {
+				return 0
+			}
+			return clamp01((l.height.Float(dx, dy) - sealevel) / (highland - sealevel))
+		},
+		CoalDeposit: func(dx, dy, i int, r Rock) float64 {
+			if r != RockSedimentary {
+				return 0
+			}
+			wet := l.AnnualRainfallMM(dx, dy) / cfg.Vegetation.Rainfall
+			if l.swamp.Value(dx, dy) > 0 {
+				wet = 1
+			}
+			return clamp01(wet) * clamp01(1-(l.height.Float(dx, dy)-sealevel)/(highland-sealevel))
+		},
+		SaltDeposit: func(dx, dy, i int, r Rock) float64 {
+			if r != RockSedimentary {
+				return 0
+			}
+			return clamp01(1 - l.AnnualRainfallMM(dx, dy)/(cfg.Soil.DryRain*2))
+		},
+		GemDeposit: func(dx, dy, i int, r Rock) float64 {
+			if r == RockSedimentary {
+				return 0
+			}
+			return 0.5 * nearBoundary(i) * clamp01((l.height.Float(dx, dy)-sealevel)/(highland-sealevel))
+		},
+	}
+
+	pois := []*POI{}
+	add := func(p *POI) bool {
+		for _, other := range pois {
+			if other.Type == p.Type && math.Hypot(float64(other.X-p.X), float64(other.Y-p.Y)) < gcfg.DepositMinDist {
+				return false
+			}
+		}
+		pois = append(pois, p)
+		return true
+	}
+
+	// placer gold, washed down from metamorphic highlands, collects
+	// where rivers slow down in the lowlands
+	if l.network != nil {
+		for _, river := range l.network.Rivers {
+			source := 0.0 // how much gold bearing rock the river has run through
+			for _, p := range river.Points {
+				if toRock(out.Value(p.X, p.Y)) == RockMetamorphic && p.Height >= highland {
+					source += 1
+				} else if source > 0 && p.Height < highland && l.sea.Value(p.X, p.Y) != 255 {
+					add(&POI{X: p.X, Y: p.Y, Type: GoldDeposit, Richness: clamp01(source / 20)})
+					break
+				}
+			}
+		}
+	}
+
+	order := []PointType{IronDeposit, CopperDeposit, GoldDeposit, CoalDeposit, SaltDeposit, GemDeposit}
+	for n, kind := range order {
+		err := t.Progress(0.5 + float64(n)/float64(len(order))/2)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		want := int(gcfg.Deposits)
+		if kind == GemDeposit {
+			want = (want + 1) / 2 // gems are rare
+		}
+		if x == 0 || y == 0 {
+			want = 0 // nowhere to put them
+		}
+		found := 0
+		for attempt := 0; attempt < want*100 && found < want; attempt++ {
+			dx, dy := rng.Intn(x), rng.Intn(y)
+			if l.sea.Value(dx, dy) == 255 || l.volcanic.Value(dx, dy) == 255 {
+				continue
+			}
+			f := favour[kind](dx, dy, dy*x+dx, toRock(out.Value(dx, dy)))
+			if rng.Float64() >= f {
+				continue
+			}
+			if add(&POI{X: dx, Y: dy, Type: kind, Richness: f * (0.5 + 0.5*rng.Float64())}) {
+				found++
+			}
+		}
+	}
+
+	return out, pois, nil
+}
@@ -0,0 +1,99 @@
+package landscape
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvinces(t *testing.T) {
+	graph, cells, err := provinces(rand.New(rand.NewSource(1)), 50, 40, 5, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(graph.Cells))
+	assert.Equal(t, 50*40, len(cells))
+
+	// every pixel is in the cell of it's nearest site
+	for i, c := range cells {
+		site := graph.Cells[c].Site
+		d := math.Hypot(site.X-float64(i%50), site.Y-float64(i/50))
+		for _, other := range graph.Cells {
+			assert.True(t, d <= math.Hypot(other.Site.X-float64(i%50), other.Site.Y-float64(i/50)))
+		}
+	}
+}
+
+func TestGeologyNoProvinces(t *testing.T) {
+	_, _, err := provinces(rand.New(rand.NewSource(1)), 50, 40, 0, false)
+	assert.NotNil(t, err)
+
+	cfg := DefaultConfig()
+	cfg.Width, cfg.Height = 60, 60
+	cfg.Geology.Provinces = 0
+
+	_, err = PerlinLandscape(cfg)
+	assert.NotNil(t, err)
+}
+
+func TestGeologyTinyMap(t *testing.T) {
+	// nb. the heightmap of a map this small has no pixels at all
+	cfg := DefaultConfig()
+	cfg.Width, cfg.Height = 3, 3
+
+	_, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+}
+
+func TestGeology(t *testing.T) {
+	cfg := testConfig()
+	l, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+
+	x, y := l.Dimensions()
+	seen := map[Rock]bool{}
+	for dx := 0; dx < x; dx++ {
+		for dy := 0; dy < y; dy++ {
+			a := l.At(dx, dy)
+			assert.NotEqual(t, RockNone, a.Rock)
+			if a.Volcanism > 0 {
+				assert.Equal(t, RockIgneous, a.Rock)
+			}
+			seen[a.Rock] = true
+		}
+	}
+	assert.True(t, len(seen) > 1)
+
+	deposits := map[PointType][]*POI{}
+	for _, p := range l.PointsOfInterest() {
+		switch p.Type {
+		case IronDeposit, CopperDeposit, GoldDeposit, CoalDeposit, SaltDeposit, GemDeposit:
+		default:
+			assert.Equal(t, 0.0, p.Richness)
+			continue
+		}
+		deposits[p.Type] = append(deposits[p.Type], p)
+		assert.True(t, p.Richness > 0 && p.Richness <= 1, p)
+
+		a := l.RiverAt(p.X, p.Y)
+		assert.False(t, a.Sea)
+		switch p.Type {
+		case GoldDeposit:
+			// in the hills or washed down a river
+			assert.True(t, a.Rock == RockMetamorphic || a.River, p)
+		case CoalDeposit, SaltDeposit:
+			assert.Equal(t, RockSedimentary, a.Rock, p)
+		case GemDeposit:
+			assert.NotEqual(t, RockSedimentary, a.Rock, p)
+		}
+	}
+	assert.True(t, len(deposits) > 1)
+
+	for _, found := range deposits {
+		for i, a := range found {
+			for _, b := range found[i+1:] {
+				assert.True(t, math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y)) >= cfg.Geology.DepositMinDist)
+			}
+		}
+	}
+}
@@ -3,20 +3,152 @@ package landscape
 import (
 	"image"
 	"image/color"
+	"math"
+
+	"github.com/voidshard/cartographer/pkg/shapes"
 )
 
+// MapImage is a single channel map holding one value per pixel.
+// Values are 0-255, stored either as uint8 or (for maps that need finer
+// steps, like height) as uint16 where each 0-255 step is 257 sub-steps.
+// By default values are rendered as greyscale (8 or 16 bit), but if a
+// palette is given each value is rendered as the colour at that index.
 type MapImage struct {
-	im *image.RGBA
+	x     int
+	y     int
+	pix   []uint8
+	pix16 []uint16 // set instead of pix for 16 bit maps
+
+	// optional palette, if set values are indexes into it
+	palette color.Palette
+
+	// if set the map wraps east-west, so the left edge meets the right edge
+	wrapX bool
 }
 
 func NewMapImage(x, y int) *MapImage {
-	return &MapImage{im: image.NewRGBA(image.Rect(0, 0, x, y))}
+	return &MapImage{x: x, y: y, pix: make([]uint8, x*y)}
+}
+
+// NewMapImage16 returns a new map storing 16 bits per pixel
+func NewMapImage16(x, y int) *MapImage {
+	return &MapImage{x: x, y: y, pix16: make([]uint16, x*y)}
+}
+
+// newPalettedMapImage returns a new map whose values are rendered as
+// colours in the given palette
+func newPalettedMapImage(x, y int, palette color.Palette) *MapImage {
+	m := NewMapImage(x, y)
+	m.palette = palette
+	return m
+}
+
+// newMapImageFrom copies the given image into a new map (with the given
+// palette, if any). 16 bit greyscale images give 16 bit maps & paletted
+// images keep their indexes.
+func newMapImageFrom(in image.Image, palette color.Palette) *MapImage {
+	rect := in.Bounds()
+
+	switch im := in.(type) {
+	case *image.Gray:
+		if palette == nil {
+			m := NewMapImage(rect.Dx(), rect.Dy())
+			for dy := 0; dy < m.y; dy++ {
+				copy(m.pix[dy*m.x:(dy+1)*m.x], im.Pix[dy*im.Stride:])
+			}
+			return m
+		}
+	case *image.RGBA:
+		if palette == nil {
+			// we only need the red channel
+			m := NewMapImage(rect.Dx(), rect.Dy())
+			for dy := 0; dy < m.y; dy++ {
+				for dx := 0; dx < m.x; dx++ {
+					m.pix[dy*m.x+dx] = im.Pix[dy*im.Stride+dx*4]
+				}
+			}
+			return m
+		}
+	case *image.Paletted:
+		if palette != nil {
+			// keep the indexes as they are, our palette may colour them differently
+			m := newPalettedMapImage(rect.Dx(), rect.Dy(), palette)
+			for dy := 0; dy < m.y; dy++ {
+				copy(m.pix[dy*m.x:(dy+1)*m.x], im.Pix[dy*im.Stride:])
+			}
+			return m
+		}
+	case *image.Gray16:
+		if palette == nil {
+			m := NewMapImage16(rect.Dx(), rect.Dy())
+			for dy := 0; dy < m.y; dy++ {
+				for dx := 0; dx < m.x; dx++ {
+					m.pix16[dy*m.x+dx] = im.Gray16At(rect.Min.X+dx, rect.Min.Y+dy).Y
+				}
+			}
+			return m
+		}
+	}
+
+	m := newPalettedMapImage(rect.Dx(), rect.Dy(), palette)
+	for dy := 0; dy < m.y; dy++ {
+		for dx := 0; dx < m.x; dx++ {
+			m.Set(dx, dy, in.At(rect.Min.X+dx, rect.Min.Y+dy))
+		}
+	}
+	return m
+}
+
+// WrapsX returns if the map wraps east-west (see Config.WrapX), in which case
+// pixels off the left or right edge are those on the opposite side
+func (m *MapImage) WrapsX() bool {
+	return m.wrapX
+}
+
+// wrap returns x moved onto the map if we wrap east-west
+func (m *MapImage) wrap(x int) int {
+	if m.wrapX && (x < 0 || x >= m.x) {
+		x = wrapCoord(x, m.x)
+	}
+	return x
+}
+
+// neighbour returns the index of the pixel offset by d from the pixel with
+// index i (y * width + x), or false if it is off the map
+func (m *MapImage) neighbour(i int, d [2]int) (int, bool) {
+	nx := m.wrap(i%m.x + d[0])
+	ny := i/m.x + d[1]
+	if nx < 0 || ny < 0 || nx >= m.x || ny >= m.y {
+		return 0, false
+	}
+	return ny*m.x + nx, true
+}
+
+// dist returns the distance between a & b, going across the east-west edge
+// of the map if we wrap & that is shorter
+func (m *MapImage) dist(a, b *shapes.Point) float64 {
+	dx := math.Abs(a.X - b.X)
+	if m.wrapX {
+		dx = math.Min(dx, float64(m.x)-dx)
+	}
+	return math.Hypot(dx, a.Y-b.Y)
+}
+
+// Is16Bit returns if the map stores 16 bits per pixel
+func (m *MapImage) Is16Bit() bool {
+	return m.pix16 != nil
 }
 
 func (m *MapImage) SetBackground(v uint8) {
-	eachPixel(m, func(dx, dy int, _ uint8) {
-		m.SetValue(dx, dy, v)
-	})
+	if m.Is16Bit() {
+		for i := range m.pix16 {
+			m.pix16[i] = uint16(v) * 257
+		}
+		return
+	}
+	for i := range m.pix {
+		m.pix[i] = v
+	}
 }
 
 func (m *MapImage) Pixel(x, y int) *Pixel {
@@ -24,39 +156,128 @@ func (m *MapImage) Pixel(x, y int) *Pixel {
 }
 
 func (m *MapImage) ColorModel() color.Model {
-	return m.im.ColorModel()
+	if m.palette != nil {
+		return m.palette
+	}
+	if m.Is16Bit() {
+		return color.Gray16Model
+	}
+	return color.GrayModel
 }
 
 func (m *MapImage) Bounds() image.Rectangle {
-	return m.im.Bounds()
+	return image.Rect(0, 0, m.x, m.y)
 }
 
 func (m *MapImage) At(x, y int) color.Color {
-	return m.im.At(x, y)
+	if m.Is16Bit() {
+		return color.Gray16{Y: m.Value16(x, y)}
+	}
+	v := m.Value(x, y)
+	if m.palette != nil {
+		if int(v) >= len(m.palette) {
+			return color.Gray{}
+		}
+		return m.palette[v]
+	}
+	return color.Gray{Y: v}
 }
 
+// ColorIndexAt returns the palette index at x,y (the same as Value). This
+// allows paletted maps to be encoded as paletted PNGs keeping exact indexes.
+func (m *MapImage) ColorIndexAt(x, y int) uint8 {
+	return m.Value(x, y)
+}
+
+// SetValue sets the value at x,y, pixels off the map are ignored
 func (m *MapImage) SetValue(x, y int, v uint8) {
-	m.im.Set(x, y, color.RGBA{v, v, v, 255})
+	x = m.wrap(x)
+	if x < 0 || y < 0 || x >= m.x || y >= m.y {
+		return
+	}
+	if m.Is16Bit() {
+		m.pix16[y*m.x+x] = uint16(v) * 257
+		return
+	}
+	m.pix[y*m.x+x] = v
 }
 
+// SetValue16 sets the value at x,y where 65535 is the max value
+// (255 in SetValue). 8 bit maps keep only the nearest 8 bit value.
+func (m *MapImage) SetValue16(x, y int, v uint16) {
+	x = m.wrap(x)
+	if x < 0 || y < 0 || x >= m.x || y >= m.y {
+		return
+	}
+	if m.Is16Bit() {
+		m.pix16[y*m.x+x] = v
+		return
+	}
+	m.pix[y*m.x+x] = uint8((uint32(v) + 128) / 257)
+}
+
+// SetFloat sets the value at x,y from a (fractional) 0-255 value, values
+// outside this range are clamped
+func (m *MapImage) SetFloat(x, y int, v float64) {
+	if v <= 0 {
+		m.SetValue16(x, y, 0)
+	} else if v >= 255 {
+		m.SetValue16(x, y, 65535)
+	} else {
+		m.SetValue16(x, y, uint16(math.Round(v*257)))
+	}
+}
+
+// Set sets the value at x,y to the given colour, either the index of the
+// closest colour in our palette or the colour's grey value
 func (m *MapImage) Set(x, y int, c color.Color) {
-	m.im.Set(x, y, c)
+	if m.palette != nil {
+		m.SetValue(x, y, uint8(m.palette.Index(c)))
+		return
+	}
+	m.SetValue16(x, y, color.Gray16Model.Convert(c).(color.Gray16).Y)
 }
 
+// Value returns the value at x,y, pixels off the map are 0.
+// For 16 bit maps this is rounded down to the nearest 8 bit value.
 func (m *MapImage) Value(x, y int) uint8 {
-	r, _, _, _ := m.At(x, y).RGBA()
-	return uint8(r)
+	x = m.wrap(x)
+	if x < 0 || y < 0 || x >= m.x || y >= m.y {
+		return 0
+	}
+	if m.Is16Bit() {
+		return uint8(m.pix16[y*m.x+x] / 257)
+	}
+	return m.pix[y*m.x+x]
+}
+
+// Value16 returns the value at x,y where 65535 is the max value
+// (255 in Value), pixels off the map are 0
+func (m *MapImage) Value16(x, y int) uint16 {
+	x = m.wrap(x)
+	if x < 0 || y < 0 || x >= m.x || y >= m.y {
+		return 0
+	}
+	if m.Is16Bit() {
+		return m.pix16[y*m.x+x]
+	}
+	return uint16(m.pix[y*m.x+x]) * 257
+}
+
+// Float returns the value at x,y as a (fractional) 0-255 value
+func (m *MapImage) Float(x, y int) float64 {
+	return float64(m.Value16(x, y)) / 257
 }
 
 func (m *MapImage) Dimensions() (int, int) {
-	rect := m.im.Bounds()
-	return rect.Max.X - rect.Min.X, rect.Max.Y - rect.Min.Y
+	return m.x, m.y
 }
 
 // Cardinals returns points in the cardinal directions of `radius` distance
 // away. Nb, this is similar to Nearby but returns far fewer points
 // (at most 8).
-// We never return points off the map.
+// We never return points off the map, though if the map wraps east-west
+// points over the edge are returned from the other side.
 func (m *MapImage) Cardinals(dx, dy, radius int) []*Pixel {
 	ns := []*Pixel{}
 	if radius < 1 {
@@ -69,6 +290,7 @@ func (m *MapImage) Cardinals(dx, dy, radius int) []*Pixel {
 			if ix == dx && iy == dy {
 				continue
 			}
+			ix = m.wrap(ix)
 			if iy < 0 || iy >= y || ix < 0 || ix >= x {
 				continue // off the map
 			}
@@ -82,29 +304,48 @@ func (m *MapImage) Cardinals(dx, dy, radius int) []*Pixel {
 
 // Nearby returns all pixels nearby dx,dy within some radius.
 // If inclusive is set then the point at dx,dy is returned too.
-// We never return points off the map.
+// We never return points off the map, though if the map wraps east-west
+// points over the edge are returned from the other side.
 func (m *MapImage) Nearby(dx, dy, radius int, inclusive bool) []*Pixel {
+	if radius < 1 {
+		if inclusive {
+			return []*Pixel{pix(dx, dy, m.Value(dx, dy))}
+		}
+		return []*Pixel{}
+	}
+
+	x, y := m.Dimensions()
+	return nearby(x, y, dx, dy, radius, inclusive, m.wrapX, m.Value)
+}
+
+// nearby returns all pixels nearby dx,dy within some radius on a map of
+// size x,y where `value` gives the value of each pixel. If wrapX is set
+// pixels over the east-west edges come from the other side of the map.
+func nearby(x, y, dx, dy, radius int, inclusive, wrapX bool, value func(int, int) uint8) []*Pixel {
 	ns := []*Pixel{}
 
 	if radius < 1 {
 		if inclusive {
-			ns = append(ns, pix(dx, dy, m.Value(dx, dy)))
+			ns = append(ns, pix(dx, dy, value(dx, dy)))
 		}
 
 		return ns
 	}
 
-	x, y := m.Dimensions()
 	for iy := dy - radius; iy <= dy+radius; iy++ {
 		for ix := dx - radius; ix <= dx+radius; ix++ {
 			if ix == dx && iy == dy && !inclusive {
 				continue // is excluded from consideration
 			}
-			if iy < 0 || iy >= y || ix < 0 || ix >= x {
+			px := ix
+			if wrapX {
+				px = wrapCoord(ix, x)
+			}
+			if iy < 0 || iy >= y || px < 0 || px >= x {
 				continue // off the map
 			}
 
-			ns = append(ns, pix(ix, iy, m.Value(ix, iy)))
+			ns = append(ns, pix(px, iy, value(px, iy)))
 		}
 	}
 
@@ -0,0 +1,122 @@
+package landscape
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+func TestMapImage(t *testing.T) {
+	im := NewMapImage(3, 2)
+
+	im.SetValue(2, 1, 200)
+	im.SetValue(3, 1, 100) // off the map
+	im.Set(0, 0, color.RGBA{50, 50, 50, 255})
+
+	assert.Equal(t, uint8(200), im.Value(2, 1))
+	assert.Equal(t, uint8(50), im.Value(0, 0))
+	assert.Equal(t, uint8(0), im.Value(3, 1))
+	assert.Equal(t, uint8(0), im.Value(-1, 0))
+	assert.Equal(t, color.Gray{Y: 200}, im.At(2, 1))
+	assert.Equal(t, image.Rect(0, 0, 3, 2), im.Bounds())
+}
+
+func TestMapImagePalette(t *testing.T) {
+	im := newPalettedMapImage(2, 2, biomePalette)
+
+	im.Set(1, 1, seaColor)
+	assert.Equal(t, seaColor, im.At(1, 1))
+	assert.Equal(t, Sea, builtinBiomeTable.biome(im.Value(1, 1)))
+	assert.Equal(t, Lowlands, builtinBiomeTable.biome(im.Value(0, 0)))
+
+	// copying via the image interface should keep the palette colours
+	cp := newMapImageFrom(im, biomePalette)
+	assert.Equal(t, im.pix, cp.pix)
+}
+
+func TestNewMapImageFrom(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	rgba.Set(1, 0, color.RGBA{90, 90, 90, 255})
+	gray := image.NewGray(image.Rect(0, 0, 2, 2))
+	gray.Set(1, 0, color.Gray{Y: 90})
+
+	for _, in := range []image.Image{rgba, gray} {
+		im := newMapImageFrom(in, nil)
+		assert.Equal(t, []uint8{0, 90, 0, 0}, im.pix)
+	}
+}
+
+func TestMapImage16(t *testing.T) {
+	im := NewMapImage16(2, 2)
+
+	im.SetValue(0, 0, 200)
+	im.SetFloat(1, 0, 100.5)
+	im.SetFloat(0, 1, 300)
+	im.SetFloat(1, 1, -1)
+
+	assert.True(t, im.Is16Bit())
+	assert.Equal(t, uint8(200), im.Value(0, 0))
+	assert.Equal(t, 200.0, im.Float(0, 0))
+	assert.Equal(t, uint8(100), im.Value(1, 0))
+	assert.InDelta(t, 100.5, im.Float(1, 0), 1.0/257)
+	assert.Equal(t, uint16(65535), im.Value16(0, 1))
+	assert.Equal(t, uint16(0), im.Value16(1, 1))
+
+	// we should encode & decode as 16 bit
+	buff := new(bytes.Buffer)
+	assert.Nil(t, png.Encode(buff, im))
+	dec, err := png.Decode(buff)
+	assert.Nil(t, err)
+	assert.IsType(t, &image.Gray16{}, dec)
+	assert.Equal(t, im.pix16, newMapImageFrom(dec, nil).pix16)
+}
+
+func TestMapImage16Precision(t *testing.T) {
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	// we expect height to have many more than 256 levels
+	levels := map[uint16]bool{}
+	x, y := l.Dimensions()
+	for dy := 0; dy < y; dy++ {
+		for dx := 0; dx < x; dx++ {
+			levels[l.height.Value16(dx, dy)] = true
+		}
+	}
+	assert.True(t, len(levels) > 256)
+}
+
+func TestMapImageWrapX(t *testing.T) {
+	im := NewMapImage(10, 5)
+	im.SetValue(9, 2, 200)
+	im.SetValue(0, 2, 100)
+
+	// without wrapping the map edge is a hard border
+	assert.Equal(t, uint8(0), im.Value(-1, 2))
+	assert.Equal(t, 6, len(im.Nearby(0, 2, 1, true)))
+	_, ok := im.neighbour(2*10, [2]int{-1, 0})
+	assert.False(t, ok)
+
+	im.wrapX = true
+	assert.Equal(t, uint8(200), im.Value(-1, 2))
+	assert.Equal(t, uint8(100), im.Value(10, 2))
+	assert.Equal(t, 9, len(im.Nearby(0, 2, 1, true)))
+	assert.Equal(t, 8, len(im.Cardinals(0, 2, 1)))
+
+	n, ok := im.neighbour(2*10, [2]int{-1, 0})
+	assert.True(t, ok)
+	assert.Equal(t, 2*10+9, n)
+	_, ok = im.neighbour(0, [2]int{0, -1})
+	assert.False(t, ok)
+
+	im.SetValue(-2, 0, 50)
+	assert.Equal(t, uint8(50), im.Value(8, 0))
+
+	assert.Equal(t, 2.0, im.dist(shapes.Pt(9, 0), shapes.Pt(1, 0)))
+}
@@ -3,18 +3,20 @@ package landscape
 import (
 	"encoding/json"
 	"fmt"
-	"image"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 // Landscape represents some landmass(es) with associated
-// maps. Images are currently greyscale uint8 (0-255) maps.
+// maps. Images are greyscale (0-255) maps, where height, temperature &
+// rainfall are held at 16 bit precision.
 type Landscape struct {
 	// map of height in m from the sea bottom.
 	// Very roughly a point of height is around 63m, thus with sea level
-	// at 115, 255 indicates (140 * 63) 8820m (Mt Everest)
+	// at 115, 255 indicates (140 * 63) 8820m (Mt Everest).
+	// See Scale & ElevationMeters
 	height *MapImage
 
 	// map of sea/not sea where 255 => sea, 0 => not
@@ -23,12 +25,18 @@ type Landscape struct {
 	// map of river (or lake) vs not where 255 => fresh water, 0 => not
 	rivers *MapImage
 
-	// maps where each draws out a single river, using 255 for river
-	// and 1-254 for lake pixels
-	rivermaps []*MapImage
+	// the pixels of each river, using 255 for river and 1-254 for lake pixels
+	rivermaps []*riverMap
+
+	// which river & lake is at each pixel
+	riverIDs *riverIDs
+
+	// each river, how they flow & join
+	network *RiverNetwork
 
 	// map of average temperature (no wind chill) where values
-	// are in degrees c + 100 (so 100 => 0c, 120 => 20c, 60 => -40c)
+	// are in degrees c + 100 (so 100 => 0c, 120 => 20c, 60 => -40c).
+	// See TemperatureCelsius
 	temperature *MapImage
 
 	// map of average rainfall .. unsure on exactly what this means ..
@@ -39,24 +47,73 @@ type Landscape struct {
 	// but high water
 	swamp *MapImage
 
+	// ocean currents (see CurrentAt)
+	currents    *MapImage
+	currentTemp *MapImage
+
+	// how far each pixel is from the sea, this affects seasonal climate
+	continentality *MapImage
+
 	// areas of geothermal activity
 	volcanic *MapImage
 
+	// how tectonically active each pixel is (HeightmapTectonic only)
+	tectonics *MapImage
+
 	// interesting points on the map
 	pointsOfInterest []*POI
 
 	//
 	biomes *MapImage
+
+	// biome IDs -> biomes, including any user defined biomes (see BiomeRule)
+	biomeTable *biomeTable
+
+	// how densely vegetated each pixel is, 255 => dense forest
+	vegetation *MapImage
+
+	// bedrock (by ID)
+	geology *MapImage
+
+	// soil type (by ID) & how fertile the soil is
+	soil      *MapImage
+	fertility *MapImage
+
+	// how biomes blend into each other (see BiomeMix)
+	biomeSecondary  *MapImage
+	biomeConfidence *MapImage
+	biomeEdge       *MapImage
+
+	// layers added by user defined stages
+	custom map[Layer]*MapImage
+
+	// guards custom & pointsOfInterest, which stages running at the same
+	// time may add to
+	lock sync.RWMutex
+
+	// the config used to generate this landscape
+	config *Config
+}
+
+// Config returns the config used to generate the landscape
+func (l *Landscape) Config() *Config {
+	return l.config
 }
 
 // PointsOfInterest returns `POI` or `Points of Interest` - these
 // are denoted via (X,Y) co-ords (in pixels) and a `PointType`
 func (l *Landscape) PointsOfInterest() []*POI {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
 	return l.pointsOfInterest
 }
 
 // Dimensions returns the width & height of each map in pixels.
 func (l *Landscape) Dimensions() (int, int) {
+	if l.height == nil {
+		// we're still being generated
+		return int(l.config.Width), int(l.config.Height)
+	}
 	return l.height.Dimensions()
 }
 
@@ -72,15 +129,23 @@ func (l *Landscape) At(x, y int) *Area {
 		return nil
 	}
 	return &Area{
-		Height:      l.height.Value(x, y),
-		Rainfall:    l.rainfall.Value(x, y),
-		Sea:         l.sea.Value(x, y) == 255,
-		River:       l.rivers.Value(x, y) == 255,
-		Temperature: l.temperature.Value(x, y),
-		Swamp:       l.swamp.Value(x, y) == 255,
-		Volcanism:   l.volcanic.Value(x, y),
-		Lava:        l.volcanic.Value(x, y) == 255,
-		Biome:       toBiome(l.biomes.At(x, y)),
+		Height:             l.height.Float(x, y),
+		Rainfall:           l.rainfall.Float(x, y),
+		Sea:                l.sea.Value(x, y) == 255,
+		River:              l.rivers.Value(x, y) == 255,
+		Temperature:        l.temperature.Float(x, y),
+		Swamp:              l.swamp.Value(x, y) == 255,
+		Volcanism:          l.volcanic.Value(x, y),
+		Lava:               l.volcanic.Value(x, y) == 255,
+		Biome:              l.biomeAt(x, y),
+		Vegetation:         l.vegetationAt(x, y),
+		Soil:               l.SoilAt(x, y),
+		Rock:               l.RockAt(x, y),
+		Fertility:          l.fertilityAt(x, y),
+		ElevationMeters:    l.ElevationMeters(x, y),
+		DepthMeters:        l.DepthMeters(x, y),
+		TemperatureCelsius: l.TemperatureCelsius(x, y),
+		AnnualRainfallMM:   l.AnnualRainfallMM(x, y),
 	}
 }
 
@@ -91,29 +156,47 @@ func (l *Landscape) RiverAt(x, y int) *Area {
 		return a
 	}
 
-	// figure out which river
-	for i, m := range l.rivermaps {
-		// note that;
-		// 0 -> not a river
-		// 1-254 -> lake id (on river)
-		// 255 -> is a river
-		rv := m.Value(x, y)
-		if rv == 0 {
-			// not a river
-			continue
-		}
-		a.RiverID = i + 1 // reserve 0 as 'not a river id'
-		if rv != 255 {
-			// use first lake
-			a.LakeID = int(rv)
-			a.Lake = true
-			break
-		}
+	if l.riverIDs == nil {
+		return a
 	}
 
+	// figure out which river (0 is reserved as 'not a river id')
+	a.RiverID, a.LakeID = l.riverIDs.At(x, y)
+	a.Lake = a.LakeID != 0
+
 	return a
 }
 
+// RiverMap draws out the given river (by ID) where 255 is river and 1-254
+// is a lake (by lake ID). Returns nil if there is no such river.
+func (l *Landscape) RiverMap(id int) *MapImage {
+	if id < 1 || id > len(l.rivermaps) {
+		return nil
+	}
+	return l.rivermaps[id-1].Image()
+}
+
+// setWrapX sets if each of our maps wraps east-west (see Config.WrapX)
+func (l *Landscape) setWrapX(wrap bool) {
+	for _, im := range l.builtinLayers() {
+		if *im != nil {
+			(*im).wrapX = wrap
+		}
+	}
+	for _, im := range l.custom {
+		im.wrapX = wrap
+	}
+	for _, r := range l.rivermaps {
+		r.wrapX = wrap
+	}
+}
+
+// setRiverMaps sets the pixels of each river & builds our river ID layers
+func (l *Landscape) setRiverMaps(x, y int, rivers []*riverMap) {
+	l.rivermaps = rivers
+	l.riverIDs = newRiverIDs(x, y, rivers)
+}
+
 // DebugRender renders out the various maps we have to an
 // OS temp dir.
 func (w *Landscape) DebugRender() (string, error) {
@@ -122,7 +205,7 @@ func (w *Landscape) DebugRender() (string, error) {
 	// write out main maps
 	for _, i := range []struct {
 		Name string
-		Img  image.Image
+		Img  *MapImage
 	}{
 		{"height.png", w.height},
 		{"sea.png", w.sea},
@@ -132,16 +215,38 @@ func (w *Landscape) DebugRender() (string, error) {
 		{"volcanism.png", w.volcanic},
 		{"swamp.png", w.swamp},
 		{"biomes.png", w.biomes},
+		{"currents.png", w.currents},
+		{"current-temp.png", w.currentTemp},
+		{"continentality.png", w.continentality},
+		{"biome-secondary.png", w.biomeSecondary},
+		{"biome-confidence.png", w.biomeConfidence},
+		{"biome-edge.png", w.biomeEdge},
+		{"vegetation.png", w.vegetation},
+		{"geology.png", w.geology},
+		{"soil.png", w.soil},
+		{"fertility.png", w.fertility},
+		{"tectonics.png", w.tectonics},
 	} {
+		if i.Img == nil {
+			continue // eg. layers missing from older archives
+		}
 		err := savePng(filepath.Join(d, i.Name), i.Img)
 		if err != nil {
 			return d, err
 		}
 	}
 
+	// write out any layers added by custom stages
+	for _, name := range w.CustomLayers() {
+		err := savePng(filepath.Join(d, string(name)+".png"), w.custom[name])
+		if err != nil {
+			return d, err
+		}
+	}
+
 	// write out river maps
 	for i := range w.rivermaps {
-		err := savePng(filepath.Join(d, fmt.Sprintf("river.%d.png", i)), w.rivermaps[i])
+		err := savePng(filepath.Join(d, fmt.Sprintf("river.%d.png", i)), w.RiverMap(i+1))
 		if err != nil {
 			return d, err
 		}
@@ -0,0 +1,179 @@
+package landscape
+
+import (
+	"image/color"
+	"sort"
+)
+
+// Layer names some output of generation held by a Landscape.
+// Built in layers are given below, but stages are free to add their own
+// via Landscape.SetLayer.
+type Layer string
+
+const (
+	LayerHeight      Layer = "height"
+	LayerSea         Layer = "sea"
+	LayerRivers      Layer = "rivers" // fresh water map & each individual river
+	LayerTemperature Layer = "temperature"
+	LayerRainfall    Layer = "rainfall"
+	LayerSwamp       Layer = "swamp"
+	LayerVolcanic    Layer = "volcanic"
+	LayerBiomes      Layer = "biomes"
+	LayerCurrents    Layer = "currents"     // heading of ocean currents (heading + 1, 0 => none)
+	LayerCurrentTemp Layer = "current-temp" // warmth of ocean currents in c + 128
+
+	// how far from the sea (0 => sea / coast, 255 => far inland)
+	LayerContinentality Layer = "continentality"
+
+	// blending between biomes (see BiomeMix)
+	LayerBiomeSecondary  Layer = "biome-secondary"  // most common other nearby biome (as LayerBiomes)
+	LayerBiomeConfidence Layer = "biome-confidence" // how much of the area is the pixel's biome (255 => all)
+	LayerBiomeEdge       Layer = "biome-edge"       // distance in pixels to another biome (max 255)
+
+	// how densely vegetated (0 => bare, 255 => dense forest)
+	LayerVegetation Layer = "vegetation"
+
+	LayerSoil      Layer = "soil"      // soil type by ID (see Soil)
+	LayerFertility Layer = "fertility" // how fertile (0 => barren, 255 => very)
+	LayerGeology   Layer = "geology"   // bedrock by ID (see Rock)
+
+	// how tectonically active (0 => none, 255 => a volcanic arc), see HeightmapTectonic
+	LayerTectonics Layer = "tectonics"
+
+	// LayerPOI is not an image but our list of points of interest,
+	// stages that read or add POIs should declare it
+	LayerPOI Layer = "pois"
+)
+
+// builtinLayerNames holds our built in image layers in a fixed order
+var builtinLayerNames = []Layer{
+	LayerHeight,
+	LayerSea,
+	LayerRivers,
+	LayerTemperature,
+	LayerRainfall,
+	LayerSwamp,
+	LayerVolcanic,
+	LayerBiomes,
+	LayerCurrents,
+	LayerCurrentTemp,
+	LayerContinentality,
+	LayerBiomeSecondary,
+	LayerBiomeConfidence,
+	LayerBiomeEdge,
+	LayerVegetation,
+	LayerSoil,
+	LayerFertility,
+	LayerGeology,
+	LayerTectonics,
+}
+
+// builtinLayers returns pointers to each of our built in image layers
+func (l *Landscape) builtinLayers() map[Layer]**MapImage {
+	return map[Layer]**MapImage{
+		LayerHeight:      &l.height,
+		LayerSea:         &l.sea,
+		LayerRivers:      &l.rivers,
+		LayerTemperature: &l.temperature,
+		LayerRainfall:    &l.rainfall,
+		LayerSwamp:       &l.swamp,
+		LayerVolcanic:    &l.volcanic,
+		LayerBiomes:      &l.biomes,
+		LayerCurrents:    &l.currents,
+		LayerCurrentTemp: &l.currentTemp,
+
+		LayerContinentality: &l.continentality,
+
+		LayerBiomeSecondary:  &l.biomeSecondary,
+		LayerBiomeConfidence: &l.biomeConfidence,
+		LayerBiomeEdge:       &l.biomeEdge,
+
+		LayerVegetation: &l.vegetation,
+		LayerSoil:       &l.soil,
+		LayerFertility:  &l.fertility,
+		LayerGeology:    &l.geology,
+		LayerTectonics:  &l.tectonics,
+	}
+}
+
+// layerPalette returns the palette used to render the given layer, or nil
+// if the layer is greyscale
+func layerPalette(name Layer) color.Palette {
+	if name == LayerBiomes || name == LayerBiomeSecondary {
+		return biomePalette
+	} else if name == LayerSoil {
+		return soilPalette
+	} else if name == LayerGeology {
+		return rockPalette
+	}
+	return nil
+}
+
+// newLayerImage returns a new blank (0) map suitable for the given layer
+func newLayerImage(name Layer, x, y int) *MapImage {
+	switch name {
+	case LayerHeight, LayerTemperature, LayerRainfall, LayerCurrentTemp:
+		return NewMapImage16(x, y)
+	}
+	return newPalettedMapImage(x, y, layerPalette(name))
+}
+
+// Layer returns the map for the given layer, or nil if it is not set
+func (l *Landscape) Layer(name Layer) *MapImage {
+	im, ok := l.builtinLayers()[name]
+	if ok {
+		return *im
+	}
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.custom[name]
+}
+
+// SetLayer sets the map for the given layer
+func (l *Landscape) SetLayer(name Layer, im *MapImage) {
+	ptr, ok := l.builtinLayers()[name]
+	if ok {
+		*ptr = im
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.custom == nil {
+		l.custom = map[Layer]*MapImage{}
+	}
+	l.custom[name] = im
+}
+
+// CustomLayers returns the names of all layers that are not built in
+func (l *Landscape) CustomLayers() []Layer {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	names := []Layer{}
+	for name := range l.custom {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// AddPointsOfInterest adds POIs to the landscape
+func (l *Landscape) AddPointsOfInterest(in ...*POI) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.pointsOfInterest = append(l.pointsOfInterest, in...)
+}
+
+// layerOrBlank returns the given layer, creating a new blank (0) map
+// if the layer isn't set yet
+func (l *Landscape) layerOrBlank(name Layer) *MapImage {
+	im := l.Layer(name)
+	if im == nil {
+		x, y := l.Dimensions()
+		im = newLayerImage(name, x, y)
+		l.SetLayer(name, im)
+	}
+	return im
+}
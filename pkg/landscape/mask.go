@@ -0,0 +1,228 @@
+package landscape
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+// MaskPreset is a built in outline of where we want land (see maskSettings)
+type MaskPreset string
+
+const (
+	// MaskNone leaves the heightmap alone, land is wherever it happens to be
+	MaskNone MaskPreset = "none"
+
+	// MaskContinent is a single large continent surrounded by sea
+	MaskContinent MaskPreset = "continent"
+
+	// MaskArchipelago is a scattering of islands of various sizes
+	MaskArchipelago MaskPreset = "archipelago"
+
+	// MaskPangaea is one supercontinent covering most of the world, wrapped
+	// around a great bay of sea to the east
+	MaskPangaea MaskPreset = "pangaea"
+
+	// MaskInlandSea is land all the way to the map edges about a sea in the
+	// middle, joined to the ocean to the east & west by narrow straits
+	MaskInlandSea MaskPreset = "inland-sea"
+)
+
+// presetMask returns a map of the given preset, where land is 255 & sea 0.
+// Some presets also return a map of straits (255) that must stay open sea
+// however wide the falloff, else nil.
+func presetMask(rng *rand.Rand, x, y int, preset MaskPreset) (*MapImage, *MapImage) {
+	fx, fy := float64(x), float64(y)
+
+	// in reports if px,py is inside the ellipse centred at cx,cy (all as
+	// fractions of the map size) with radii rx,ry
+	in := func(px, py, cx, cy, rx, ry float64) bool {
+		dx := (px/fx - cx) / rx
+		dy := (py/fy - cy) / ry
+		return dx*dx+dy*dy <= 1
+	}
+
+	var land, strait func(px, py float64) bool
+	switch preset {
+	case MaskContinent:
+		land = func(px, py float64) bool {
+			return in(px, py, 0.5, 0.5, 0.35, 0.35)
+		}
+	case MaskPangaea:
+		land = func(px, py float64) bool {
+			return in(px, py, 0.5, 0.5, 0.45, 0.42) && !in(px, py, 0.85, 0.5, 0.3, 0.15)
+		}
+	case MaskInlandSea:
+		// the straits are narrower than the usual falloff, so we keep them
+		// open or the sea would be cut off from the ocean
+		strait = func(px, py float64) bool {
+			return math.Abs(py/fy-0.5) < 0.04
+		}
+		land = func(px, py float64) bool {
+			return !in(px, py, 0.5, 0.5, 0.3, 0.2) && !strait(px, py)
+		}
+	case MaskArchipelago:
+		type island struct{ cx, cy, r float64 }
+		islands := []island{}
+		for i := 0; i < 25; i++ {
+			islands = append(islands, island{
+				cx: 0.1 + 0.8*rng.Float64(),
+				cy: 0.1 + 0.8*rng.Float64(),
+				r:  0.02 + 0.06*rng.Float64(),
+			})
+		}
+		land = func(px, py float64) bool {
+			for _, i := range islands {
+				if in(px, py, i.cx, i.cy, i.r, i.r) {
+					return true
+				}
+			}
+			return false
+		}
+	default:
+		return nil, nil
+	}
+
+	out := NewMapImage(x, y)
+	var straits *MapImage
+	if strait != nil {
+		straits = NewMapImage(x, y)
+	}
+	for dx := 0; dx < x; dx++ {
+		for dy := 0; dy < y; dy++ {
+			if land(float64(dx), float64(dy)) {
+				out.SetValue(dx, dy, 255)
+			}
+			if strait != nil && strait(float64(dx), float64(dy)) {
+				straits.SetValue(dx, dy, 255)
+			}
+		}
+	}
+	return out, straits
+}
+
+// drawMask returns a map of where the given image & polygons say we want land,
+// where land is 255 & sea 0. The image is scaled to fit the map, pixels that
+// are more white than black are land. Polygons are in pixels.
+func drawMask(x, y int, im image.Image, polys []*shapes.Polygon) *MapImage {
+	out := NewMapImage(x, y)
+
+	if im != nil {
+		b := im.Bounds()
+		for dx := 0; dx < x; dx++ {
+			for dy := 0; dy < y; dy++ {
+				ix := b.Min.X + dx*b.Dx()/x
+				iy := b.Min.Y + dy*b.Dy()/y
+				g := color.GrayModel.Convert(im.At(ix, iy)).(color.Gray)
+				if g.Y >= 128 {
+					out.SetValue(dx, dy, 255)
+				}
+			}
+		}
+	}
+
+	for _, p := range polys {
+		x0, y0, x1, y1 := p.Bounds()
+		for dx := maxInt(int(x0), 0); dx <= minInt(int(x1), x-1); dx++ {
+			for dy := maxInt(int(y0), 0); dy <= minInt(int(y1), y-1); dy++ {
+				if p.Contains(shapes.Pt(float64(dx), float64(dy))) {
+					out.SetValue(dx, dy, 255)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// maskWeights returns 0-1 how much each pixel of the given mask should be
+// land, fading from 0.5 at the edge of the mask to 1 (inland) or 0 (at sea)
+// over `falloff` pixels.
+func maskWeights(t *tracker, mask *MapImage, falloff int) ([]float64, error) {
+	if falloff < 1 {
+		falloff = 1
+	}
+
+	inland, err := distanceFrom(t, mask, falloff, func(v uint8) bool { return v == 0 })
+	if err != nil {
+		return nil, err
+	}
+	offshore, err := distanceFrom(t, mask, falloff, func(v uint8) bool { return v == 255 })
+	if err != nil {
+		return nil, err
+	}
+
+	x, _ := mask.Dimensions()
+	weights := make([]float64, len(inland))
+	for i := range weights {
+		if mask.Value(i%x, i/x) == 255 {
+			weights[i] = 1
+			if inland[i] >= 0 {
+				weights[i] = 0.5 + 0.5*float64(inland[i])/float64(falloff)
+			}
+		} else if offshore[i] >= 0 {
+			weights[i] = 0.5 - 0.5*float64(offshore[i])/float64(falloff)
+		}
+	}
+	return weights, nil
+}
+
+// applyMask reshapes the heightmap so that land is where the mask (see
+// maskSettings) says it should be. Heights are squashed above sea level within
+// the mask & below it outside, keeping the relief of the original heightmap.
+// Across the falloff about the edge of the mask higher ground stays land &
+// lower ground floods, so the coast follows the lie of the land.
+func applyMask(t *tracker, rng *rand.Rand, hmap *MapImage, sealevel uint8, ms *maskSettings) error {
+	x, y := hmap.Dimensions()
+
+	var mask, straits *MapImage
+	if ms.Image != nil || len(ms.Shapes) > 0 {
+		mask = drawMask(x, y, ms.Image, ms.Shapes)
+	} else {
+		mask, straits = presetMask(rng, x, y, ms.Preset)
+	}
+	if mask == nil {
+		return nil // nothing to do
+	}
+
+	weights, err := maskWeights(t, mask, int(ms.Falloff))
+	if err != nil {
+		return err
+	}
+	if straits != nil {
+		// straits are always open sea
+		for i := range weights {
+			if straits.Value(i%x, i/x) == 255 {
+				weights[i] = 0
+			}
+		}
+	}
+
+	level := float64(sealevel)
+	for dy := 0; dy < y; dy++ {
+		err := t.Progress(float64(dy) / float64(y))
+		if err != nil {
+			return err
+		}
+
+		for dx := 0; dx < x; dx++ {
+			h := hmap.Float(dx, dy)
+
+			// -1 (deepest sea) to 1 (highest peak), where the whole mask
+			// is above sea level & everything outside it below
+			v := h/255 + weights[dy*x+dx] - 1
+
+			shaped := level + v*level
+			if v >= 0 {
+				shaped = level + 1 + v*(254-level)
+			}
+
+			hmap.SetFloat(dx, dy, h*(1-ms.Strength)+shaped*ms.Strength)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package landscape
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+func square(x0, y0, x1, y1 float64) *shapes.Polygon {
+	return shapes.NewPolygon([]*shapes.Point{
+		shapes.Pt(x0, y0),
+		shapes.Pt(x0, y1),
+		shapes.Pt(x1, y1),
+		shapes.Pt(x1, y0),
+	})
+}
+
+func TestPresetMask(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	none, straits := presetMask(rng, 100, 100, MaskNone)
+	assert.Nil(t, none)
+	assert.Nil(t, straits)
+
+	continent, _ := presetMask(rng, 100, 100, MaskContinent)
+	assert.Equal(t, uint8(255), continent.Value(50, 50))
+	assert.Equal(t, uint8(0), continent.Value(0, 0))
+
+	pangaea, _ := presetMask(rng, 100, 100, MaskPangaea)
+	assert.Equal(t, uint8(255), pangaea.Value(30, 50))
+	assert.Equal(t, uint8(0), pangaea.Value(90, 50))
+
+	inland, straits := presetMask(rng, 100, 100, MaskInlandSea)
+	assert.Equal(t, uint8(0), inland.Value(50, 50))
+	assert.Equal(t, uint8(0), inland.Value(0, 50))
+	assert.Equal(t, uint8(0), inland.Value(99, 50))
+	assert.Equal(t, uint8(255), inland.Value(0, 0))
+	assert.Equal(t, uint8(255), straits.Value(0, 50))
+	assert.Equal(t, uint8(255), straits.Value(99, 50))
+	assert.Equal(t, uint8(0), straits.Value(0, 0))
+
+	islands, _ := presetMask(rng, 100, 100, MaskArchipelago)
+	land := 0
+	eachPixel(islands, func(dx, dy int, c uint8) {
+		if c == 255 {
+			land++
+		}
+	})
+	assert.True(t, land > 0)
+	assert.True(t, land < 100*100/2)
+}
+
+func TestDrawMask(t *testing.T) {
+	// white top left corner
+	im := image.NewGray(image.Rect(0, 0, 2, 2))
+	im.SetGray(0, 0, color.Gray{Y: 255})
+
+	mask := drawMask(10, 10, im, []*shapes.Polygon{square(6, 6, 8, 8)})
+
+	assert.Equal(t, uint8(255), mask.Value(0, 0))
+	assert.Equal(t, uint8(255), mask.Value(4, 4))
+	assert.Equal(t, uint8(0), mask.Value(5, 5))
+	assert.Equal(t, uint8(255), mask.Value(7, 7))
+	assert.Equal(t, uint8(0), mask.Value(9, 9))
+	assert.Equal(t, uint8(0), mask.Value(9, 0))
+}
+
+func TestMaskWeights(t *testing.T) {
+	mask := drawMask(20, 1, nil, []*shapes.Polygon{square(-1, -1, 9.5, 2)})
+	tr := newReporter(context.Background(), nil).stage(StageMask)
+
+	weights, err := maskWeights(tr, mask, 4)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1.0, weights[0])
+	assert.Equal(t, 0.75, weights[8])
+	assert.Equal(t, 0.625, weights[9]) // the edge is between 9 & 10
+	assert.Equal(t, 0.375, weights[10])
+	assert.Equal(t, 0.25, weights[11])
+	assert.Equal(t, 0.0, weights[14])
+	assert.Equal(t, 0.0, weights[19])
+
+	for i := 1; i < len(weights); i++ {
+		assert.True(t, weights[i] <= weights[i-1])
+	}
+}
+
+func TestApplyMask(t *testing.T) {
+	ms := DefaultConfig().Mask
+	ms.Shapes = []*shapes.Polygon{square(10, 10, 40, 40)}
+	ms.Falloff = 5
+
+	hmap := NewMapImage16(50, 50)
+	hmap.SetBackground(128)
+
+	tr := newReporter(context.Background(), nil).stage(StageMask)
+	assert.Nil(t, applyMask(tr, rand.New(rand.NewSource(1)), hmap, 115, ms))
+
+	assert.True(t, hmap.Value(25, 25) > 115)
+	assert.True(t, hmap.Value(0, 0) < 115)
+	assert.True(t, hmap.Value(49, 25) < 115)
+}
+
+func TestMaskedLandscape(t *testing.T) {
+	cfg := testConfig()
+	cfg.Mask.Preset = MaskContinent
+	cfg.Mask.Falloff = 10
+
+	l, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+
+	for _, p := range [][2]int{{0, 0}, {199, 0}, {0, 199}, {199, 199}, {100, 2}, {2, 100}} {
+		assert.True(t, l.At(p[0], p[1]).Sea, p)
+	}
+
+	land := 0
+	for dx := 60; dx < 140; dx++ {
+		for dy := 60; dy < 140; dy++ {
+			if !l.At(dx, dy).Sea {
+				land++
+			}
+		}
+	}
+	assert.Equal(t, 80*80, land)
+}
+
+func TestPresetLandscapes(t *testing.T) {
+	// each preset at the default falloff, where points are given as
+	// fractions of the map size
+	for _, c := range []struct {
+		Preset MaskPreset
+		Sea    [][2]float64
+		Land   [][2]float64
+	}{
+		{MaskContinent, [][2]float64{{0, 0}, {0.99, 0.99}}, [][2]float64{{0.5, 0.5}}},
+		{MaskArchipelago, [][2]float64{{0, 0}, {0.99, 0.99}}, nil},
+		{MaskPangaea, [][2]float64{{0, 0}, {0.9, 0.5}}, [][2]float64{{0.4, 0.5}}},
+		{MaskInlandSea, [][2]float64{{0.5, 0.5}, {0, 0.5}, {0.99, 0.5}}, [][2]float64{{0.1, 0.1}, {0.9, 0.9}}},
+	} {
+		cfg := testConfig()
+		cfg.Mask.Preset = c.Preset
+		cfg.Volcanic.Number = 0 // volcanic land is raised out of the sea
+
+		l, err := PerlinLandscape(cfg)
+		assert.Nil(t, err)
+
+		x, y := l.Dimensions()
+		for _, p := range c.Sea {
+			assert.True(t, l.At(int(p[0]*float64(x)), int(p[1]*float64(y))).Sea, "%s %v", c.Preset, p)
+		}
+		for _, p := range c.Land {
+			assert.False(t, l.At(int(p[0]*float64(x)), int(p[1]*float64(y))).Sea, "%s %v", c.Preset, p)
+		}
+
+		sea := 0
+		eachPixel(l.sea, func(dx, dy int, v uint8) {
+			if v == 255 {
+				sea++
+			}
+		})
+		assert.True(t, sea > 0, c.Preset)
+		assert.True(t, sea < x*y, c.Preset)
+	}
+}
@@ -0,0 +1,158 @@
+package landscape
+
+import (
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+// Current is the flow of the sea at some place on the map
+type Current struct {
+	// direction the water is flowing towards
+	Heading shapes.Heading
+
+	// how much warmer (or colder, if negative) the current makes the
+	// sea in degrees c
+	Anomaly float64
+}
+
+// determineCurrents figures out ocean currents from the sea & latitude.
+// Surface water follows the prevailing winds (see windHeading) until it nears
+// a coast, where it is turned along the coast. Water pushed west (by the trade
+// winds) is turned towards the poles, carrying warm water from the equator,
+// while water pushed east (by the westerlies) is turned towards the equator
+// carrying cold water. This gives us warm currents on the east coasts of
+// continents & cold currents on the west coasts, roughly as on Earth.
+//
+// We return a map of headings (heading + 1, 0 => no current) & a map of
+// temperature anomalies in degrees c + 128 (128 => no change).
+func determineCurrents(t *tracker, sea *MapImage, rs *rainfallSettings, cfg *oceanSettings) (*MapImage, *MapImage, error) {
+	x, y := sea.Dimensions()
+
+	headings := NewMapImage(x, y)
+	anomaly := NewMapImage16(x, y)
+	anomaly.SetBackground(128)
+
+	if !cfg.Currents {
+		return headings, anomaly, nil
+	}
+
+	for dy := 0; dy < y; dy++ {
+		err := t.Progress(float64(dy) / float64(y))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		north := dy < y/2
+		poleward, equatorward := shapes.NORTH, shapes.SOUTH
+		if !north {
+			poleward, equatorward = shapes.SOUTH, shapes.NORTH
+		}
+
+		h := windHeading(rs, latitude(dy, y))
+		wx, wy := h.RiseRun()
+
+		for dx := 0; dx < x; dx++ {
+			if sea.Value(dx, dy) != 255 {
+				continue
+			}
+
+			// look ahead for a coast
+			dist := 0
+			for d := 1; d <= int(cfg.CurrentRange); d++ {
+				ax := sea.wrap(dx + wx*d)
+				ay := dy + wy*d
+				if ax < 0 || ay < 0 || ax >= x || ay >= y {
+					break
+				}
+				if sea.Value(ax, ay) != 255 {
+					dist = d
+					break
+				}
+			}
+
+			if dist == 0 {
+				// open sea, the current follows the wind
+				headings.SetValue(dx, dy, uint8(h)+1)
+				continue
+			}
+
+			turned := h
+			warm := false
+			switch {
+			case wx < 0:
+				turned = poleward
+				warm = true
+			case wx > 0:
+				turned = equatorward
+			default:
+				// already flowing north / south
+				warm = (wy < 0) == north
+			}
+
+			strength := 1 - float64(dist-1)/float64(cfg.CurrentRange)
+			temp := strength * cfg.CurrentTemp
+			if !warm {
+				temp = -temp
+			}
+
+			headings.SetValue(dx, dy, uint8(turned)+1)
+			anomaly.SetFloat(dx, dy, 128+temp)
+		}
+	}
+
+	return headings, anomaly, nil
+}
+
+// moderateCoasts adds current anomalies to the temperature of the sea & then
+// pulls the temperature of land near the coast towards that of the sea, so
+// coasts are milder than inland areas.
+func moderateCoasts(t *tracker, sea, anomaly, temp *MapImage, cfg *oceanSettings) error {
+	x, y := sea.Dimensions()
+
+	source := make([]float64, x*y)
+	for dy := 0; dy < y; dy++ {
+		for dx := 0; dx < x; dx++ {
+			if sea.Value(dx, dy) != 255 {
+				continue
+			}
+			i := dy*x + dx
+			source[i] = temp.Float(dx, dy) + anomaly.Float(dx, dy) - 128
+			temp.SetFloat(dx, dy, source[i])
+		}
+	}
+
+	if cfg.CoastalRange < 1 || cfg.CoastalModeration <= 0 {
+		return nil
+	}
+
+	// each land pixel near the coast takes the sea temperature of the
+	// nearest sea
+	dist, nearest, err := nearestFrom(t, sea, int(cfg.CoastalRange), func(v uint8) bool { return v == 255 })
+	if err != nil {
+		return err
+	}
+	for j, d := range dist {
+		if d < 1 {
+			continue
+		}
+		effect := cfg.CoastalModeration * (1 - float64(d-1)/float64(cfg.CoastalRange))
+		now := temp.Float(j%x, j/x)
+		temp.SetFloat(j%x, j/x, now+(source[nearest[j]]-now)*effect)
+	}
+
+	return nil
+}
+
+// CurrentAt returns the ocean current at x,y or nil if there isn't one
+func (l *Landscape) CurrentAt(x, y int) *Current {
+	if l.currents == nil || l.currentTemp == nil {
+		return nil
+	}
+	h := l.currents.Value(x, y)
+	if h == 0 {
+		return nil
+	}
+	return &Current{
+		Heading: shapes.Heading(h - 1),
+		Anomaly: l.currentTemp.Float(x, y) - 128,
+	}
+}
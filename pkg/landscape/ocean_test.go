@@ -0,0 +1,82 @@
+package landscape
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+func TestDetermineCurrents(t *testing.T) {
+	cfg := DefaultConfig()
+
+	// sea everywhere but a continent running north-south in the middle
+	x, y := 200, 180
+	sea := NewMapImage(x, y)
+	sea.SetBackground(255)
+	for dx := 90; dx < 110; dx++ {
+		for dy := 10; dy < 170; dy++ {
+			sea.SetValue(dx, dy, 0)
+		}
+	}
+
+	tr := newReporter(context.Background(), nil).stage(StageCurrents)
+	headings, anomaly, err := determineCurrents(tr, sea, cfg.Rain, cfg.Ocean)
+	assert.Nil(t, err)
+
+	l := &Landscape{currents: headings, currentTemp: anomaly}
+
+	// trade winds push water west onto the east coast, where it turns to the pole
+	c := l.CurrentAt(112, 80)
+	assert.Equal(t, shapes.NORTH, c.Heading)
+	assert.True(t, c.Anomaly > 0)
+
+	// westerlies push water east onto the west coast, where it turns to the equator
+	c = l.CurrentAt(88, 135)
+	assert.Equal(t, shapes.NORTH, c.Heading)
+	assert.True(t, c.Anomaly < 0)
+
+	// out at sea we follow the wind
+	c = l.CurrentAt(20, 90)
+	assert.Equal(t, shapes.WEST, c.Heading)
+	assert.Equal(t, 0.0, c.Anomaly)
+
+	// and there's no current on land
+	assert.Nil(t, l.CurrentAt(100, 90))
+
+	cfg.Ocean.Currents = false
+	headings, _, err = determineCurrents(tr, sea, cfg.Rain, cfg.Ocean)
+	assert.Nil(t, err)
+	assert.Nil(t, (&Landscape{currents: headings, currentTemp: anomaly}).CurrentAt(20, 90))
+}
+
+func TestModerateCoasts(t *testing.T) {
+	cfg := DefaultConfig().Ocean
+
+	// sea in the west, land in the east
+	x, y := 100, 10
+	sea := NewMapImage(x, y)
+	temp := NewMapImage16(x, y)
+	anomaly := NewMapImage16(x, y)
+	anomaly.SetBackground(128)
+	for dx := 0; dx < x; dx++ {
+		for dy := 0; dy < y; dy++ {
+			if dx < 20 {
+				sea.SetValue(dx, dy, 255)
+				temp.SetValue(dx, dy, 110)
+				anomaly.SetValue(dx, dy, 130) // a warm current
+			} else {
+				temp.SetValue(dx, dy, 90)
+			}
+		}
+	}
+
+	tr := newReporter(context.Background(), nil).stage(StageTemperature)
+	assert.Nil(t, moderateCoasts(tr, sea, anomaly, temp, cfg))
+
+	assert.Equal(t, 112.0, temp.Float(5, 5))
+	assert.True(t, temp.Float(20, 5) > temp.Float(40, 5))
+	assert.True(t, temp.Float(40, 5) > 90)
+	assert.Equal(t, 90.0, temp.Float(80, 5)) // beyond CoastalRange
+}
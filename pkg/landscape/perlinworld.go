@@ -1,118 +1,352 @@
 package landscape
 
 import (
-	"log"
-	"sync"
-	"time"
+	"context"
+)
 
-	perlin "github.com/voidshard/cartographer/pkg/perlin"
+// Names of the stages in our perlin pipeline, for use with Pipeline
+// InsertBefore, InsertAfter, Replace & Remove.
+const (
+	StageHeightmap   = "heightmap"
+	StageMask        = "mask"
+	StageErosion     = "erosion"
+	StageThermal     = "thermal"
+	StageGeothermal  = "geothermal"
+	StageSea         = "sea"
+	StageCurrents    = "currents"
+	StageSeasons     = "seasons"
+	StageRivers      = "rivers"
+	StageMountains   = "mountains"
+	StageSwamp       = "swamp"
+	StageTemperature = "temperature"
+	StageRainfall    = "rainfall"
+	StageBiomes      = "biomes"
+	StageEcotones    = "ecotones"
+	StageVegetation  = "vegetation"
+	StageSoil        = "soil"
+	StageGeology     = "geology"
 )
 
-func timer(in string) func() {
-	start := time.Now()
+// PerlinLandscape generates our maps from simple perlin noise & some basic math / combinations
+// The same Config (including the Seed) always produces the same Landscape.
+func PerlinLandscape(cfg *Config) (*Landscape, error) {
+	return PerlinLandscapeContext(context.Background(), cfg, nil)
+}
 
-	return func() {
-		end := time.Now()
-		log.Println(in, "took", end.Sub(start))
-	}
+// PerlinLandscapeContext is PerlinLandscape but can be cancelled via the given
+// context & reports it's progress through each stage to the given ProgressFunc
+// (which may be nil).
+func PerlinLandscapeContext(ctx context.Context, cfg *Config, fn ProgressFunc) (*Landscape, error) {
+	return PerlinPipeline().Run(ctx, cfg, fn)
 }
 
-// PerlinLandscape generates our maps from simple perlin noise & some basic math / combinations
-func PerlinLandscape(cfg *Config) (*Landscape, error) {
-	t := timer("heightmap")
-	hmap := combine(
-		weight(perlin.Perlin(int(cfg.Width), int(cfg.Height), cfg.Land.HeightVariance), 70),
-		weight(perlin.Perlin(int(cfg.Width), int(cfg.Height), cfg.Land.MountainVariance), 30),
+// PerlinPipeline returns the stages used by PerlinLandscape, this can be
+// modified to add, remove or replace stages.
+func PerlinPipeline() *Pipeline {
+	return NewPipeline(
+		NewStage(
+			StageHeightmap,
+			[]Layer{},
+			[]Layer{LayerHeight, LayerTectonics},
+			stageHeightmap,
+		),
+		// modifies heightmap
+		NewStage(
+			StageMask,
+			[]Layer{LayerHeight},
+			[]Layer{LayerHeight},
+			stageMask,
+		),
+		// modifies heightmap
+		NewStage(
+			StageErosion,
+			[]Layer{LayerHeight},
+			[]Layer{LayerHeight},
+			stageErosion,
+		),
+		// modifies heightmap
+		NewStage(
+			StageThermal,
+			[]Layer{LayerHeight},
+			[]Layer{LayerHeight},
+			stageThermal,
+		),
+		// modifies heightmap
+		// nb. geothermal outputs the temperature map because this greatly decreases
+		// our later workload increasing temperature near volcanic land
+		NewStage(
+			StageGeothermal,
+			[]Layer{LayerHeight, LayerTectonics},
+			[]Layer{LayerHeight, LayerVolcanic, LayerTemperature, LayerPOI},
+			stageGeothermal,
+		),
+		// modifies heightmap
+		NewStage(
+			StageSea,
+			[]Layer{LayerHeight},
+			[]Layer{LayerHeight, LayerSea},
+			stageSea,
+		),
+		NewStage(
+			StageCurrents,
+			[]Layer{LayerSea},
+			[]Layer{LayerCurrents, LayerCurrentTemp},
+			stageCurrents,
+		),
+		NewStage(
+			StageSeasons,
+			[]Layer{LayerSea},
+			[]Layer{LayerContinentality},
+			stageSeasons,
+		),
+		// modifies heightmap
+		// sadly, in order to run rivers to the sea, we have to know where the sea is
+		// we also want to avoid running through lava
+		NewStage(
+			StageRivers,
+			[]Layer{LayerHeight, LayerSea, LayerVolcanic},
+			[]Layer{LayerHeight, LayerRivers, LayerRainfall, LayerPOI},
+			stageRivers,
+		),
+		NewStage(
+			StageMountains,
+			[]Layer{LayerHeight},
+			[]Layer{LayerPOI},
+			stageMountains,
+		),
+		// we'll look at putting swamps at the ends of rivers
+		NewStage(
+			StageSwamp,
+			[]Layer{LayerHeight, LayerRivers, LayerSea, LayerPOI},
+			[]Layer{LayerSwamp, LayerPOI},
+			stageSwamp,
+		),
+		// adds to the temperature map from geothermal
+		NewStage(
+			StageTemperature,
+			[]Layer{LayerHeight, LayerSea, LayerCurrentTemp, LayerTemperature},
+			[]Layer{LayerTemperature},
+			stageTemperature,
+		),
+		// adds to the fresh water rainfall map from rivers
+		NewStage(
+			StageRainfall,
+			[]Layer{LayerHeight, LayerSea, LayerRainfall},
+			[]Layer{LayerRainfall},
+			stageRainfall,
+		),
+		// finally, using everything else, bucket areas into biomes
+		NewStage(
+			StageBiomes,
+			[]Layer{LayerHeight, LayerSea, LayerRivers, LayerTemperature, LayerRainfall, LayerSwamp, LayerVolcanic, LayerContinentality},
+			[]Layer{LayerBiomes},
+			stageBiomes,
+		),
+		NewStage(
+			StageVegetation,
+			[]Layer{LayerHeight, LayerSea, LayerRivers, LayerTemperature, LayerRainfall, LayerSwamp, LayerVolcanic},
+			[]Layer{LayerVegetation},
+			stageVegetation,
+		),
+		// nb. the river network is written alongside LayerRivers
+		NewStage(
+			StageGeology,
+			[]Layer{LayerHeight, LayerSea, LayerRivers, LayerRainfall, LayerSwamp, LayerVolcanic},
+			[]Layer{LayerGeology, LayerPOI},
+			stageGeology,
+		),
+		NewStage(
+			StageSoil,
+			[]Layer{LayerHeight, LayerSea, LayerRivers, LayerTemperature, LayerRainfall, LayerSwamp, LayerVolcanic, LayerGeology},
+			[]Layer{LayerSoil, LayerFertility},
+			stageSoil,
+		),
+		// blend biomes into each other
+		NewStage(
+			StageEcotones,
+			[]Layer{LayerBiomes},
+			[]Layer{LayerBiomeSecondary, LayerBiomeConfidence, LayerBiomeEdge},
+			stageEcotones,
+		),
 	)
-	t()
-
-	// modifies heightmap
-	t = timer("geothermal")
-	// nb. geothermal outputs the temperature map because this greatly decreases
-	// our later workload increasing temperature near volcanic land
-	volc, temp, pois := determineGeothermal(hmap, cfg.Sea.SeaLevel, cfg.Volcanic)
-	t()
-
-	// modifies heightmap
-	t = timer("sea")
-	sea := determineSea(hmap, cfg.Sea)
-	t()
-
-	// modifies heightmap
-	// sadly, in order to run rivers to the sea, we have to know where the sea is
-	// we also want to avoid running through lava
-	t = timer("rivers")
-	rvrs, rivermaps, rain, rpois := determineRivers(hmap, sea, volc, cfg.Rivers, cfg.Lakes)
-	pois = append(pois, rpois...)
-	t()
-
-	wg := sync.WaitGroup{}
-	wg.Add(4)
-
-	plock := &sync.Mutex{}
-	var swmp *MapImage
-
-	go func() { // locate mountains
-		tm := timer("mountains")
-		defer wg.Done()
-		mountains := findMountains(hmap)
-		plock.Lock()
-		defer plock.Unlock()
-		pois = append(pois, mountains...)
-		tm()
-	}()
-	go func() {
-		ts := timer("swamp")
-		defer wg.Done()
+}
 
-		// we'll look at putting swamps at the ends of rivers
-		plock.Lock()
-		ends := []*POI{}
-		for _, p := range pois {
-			if p.Type == RiverEnd && int(cfg.Swamp.MaxHeight) > int(hmap.Value(p.X, p.Y)) {
-				ends = append(ends, p)
-			}
+func stageHeightmap(t *Task) error {
+	cfg := t.Config
+	if cfg.Land.Mode == HeightmapTectonic {
+		height, tect, err := determineTectonics(t.tracker, t.Rand, int(cfg.Width), int(cfg.Height), cfg.Sea.SeaLevel, cfg.Land, cfg.WrapX)
+		if err != nil {
+			return err
+		}
+		t.Landscape.height = height
+		t.Landscape.tectonics = tect
+		return nil
+	}
+
+	t.Landscape.height = combine(
+		weight(noise16(t.Rand, int(cfg.Width), int(cfg.Height), cfg.Land.HeightVariance, cfg.WrapX), 70),
+		weight(noise16(t.Rand, int(cfg.Width), int(cfg.Height), cfg.Land.MountainVariance, cfg.WrapX), 30),
+	)
+	t.Landscape.tectonics = NewMapImage(int(cfg.Width), int(cfg.Height))
+	return nil
+}
+
+func stageMask(t *Task) error {
+	return applyMask(t.tracker, t.Rand, t.Landscape.height, t.Config.Sea.SeaLevel, t.Config.Mask)
+}
+
+func stageErosion(t *Task) error {
+	return determineErosion(t.tracker, t.Rand, t.Landscape.height, t.Config.Erosion)
+}
+
+func stageThermal(t *Task) error {
+	return determineThermalErosion(t.tracker, t.Landscape.height, t.Config.Thermal)
+}
+
+func stageGeothermal(t *Task) error {
+	l := t.Landscape
+	volc, temp, pois, err := determineGeothermal(t.tracker, t.Rand, l.height, l.tectonics, t.Config.Sea.SeaLevel, t.Config.Volcanic)
+	if err != nil {
+		return err
+	}
+	l.volcanic = volc
+	l.temperature = temp
+	l.AddPointsOfInterest(pois...)
+	return nil
+}
+
+func stageSea(t *Task) error {
+	sea, err := determineSea(t.tracker, t.Landscape.height, t.Config.Sea)
+	if err != nil {
+		return err
+	}
+	t.Landscape.sea = sea
+	return nil
+}
+
+func stageCurrents(t *Task) error {
+	l := t.Landscape
+	currents, temp, err := determineCurrents(t.tracker, l.sea, t.Config.Rain, t.Config.Ocean)
+	if err != nil {
+		return err
+	}
+	l.currents = currents
+	l.currentTemp = temp
+	return nil
+}
+
+func stageSeasons(t *Task) error {
+	cont, err := determineContinentality(t.tracker, t.Landscape.sea, t.Config.Seasons)
+	if err != nil {
+		return err
+	}
+	t.Landscape.continentality = cont
+	return nil
+}
+
+func stageRivers(t *Task) error {
+	l := t.Landscape
+
+	var result *riverResult
+	var err error
+	if t.Config.Rivers.Mode == RiverFlow {
+		result, err = determineFlowRivers(t.tracker, l.height, l.sea, l.volcanic, t.Config.Rivers, t.Config.Lakes)
+	} else {
+		result, err = determineRivers(t.tracker, t.Rand, l.height, l.sea, l.volcanic, t.Config.Rivers, t.Config.Lakes)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.rivers = result.rivers
+	x, y := l.height.Dimensions()
+	l.setRiverMaps(x, y, result.rivermaps)
+	l.rainfall = result.rain
+	l.network = result.network
+	l.AddPointsOfInterest(result.pois...)
+	return nil
+}
+
+func stageMountains(t *Task) error {
+	t.Landscape.AddPointsOfInterest(findMountains(t.Landscape.height)...)
+	return nil
+}
+
+func stageSwamp(t *Task) error {
+	l := t.Landscape
+
+	ends := []*POI{}
+	for _, p := range l.PointsOfInterest() {
+		if p.Type == RiverEnd && int(t.Config.Swamp.MaxHeight) > int(l.height.Value(p.X, p.Y)) {
+			ends = append(ends, p)
 		}
-		plock.Unlock()
-
-		spois := []*POI{}
-		swmp, spois = determineSwamp(hmap, rvrs, sea, cfg.Swamp, ends)
-
-		plock.Lock()
-		defer plock.Unlock()
-		pois = append(pois, spois...)
-		ts()
-	}()
-	go func() {
-		tt := timer("temperature")
-		defer wg.Done()
-		determineTemp(hmap, temp, cfg.Sea.SeaLevel, cfg.Temp)
-		tt()
-	}()
-	go func() {
-		tr := timer("rainfall")
-		defer wg.Done()
-		determineRainfall(hmap, rain, cfg.Rain)
-		tr()
-	}()
-	wg.Wait()
-
-	l := &Landscape{
-		height:           hmap,
-		sea:              sea,
-		rivers:           rvrs,
-		rivermaps:        rivermaps,
-		temperature:      temp,
-		rainfall:         rain,
-		pointsOfInterest: pois,
-		volcanic:         volc,
-		swamp:            swmp,
 	}
 
-	// finally, using everything else, bucket areas into biomes
-	t = timer("biomes")
-	l.determineBiomes(cfg)
-	t()
+	swmp, pois, err := determineSwamp(t.tracker, t.Rand, l.height, l.rivers, l.sea, t.Config.Swamp, ends)
+	if err != nil {
+		return err
+	}
+	l.swamp = swmp
+	l.AddPointsOfInterest(pois...)
+	return nil
+}
 
-	return l, nil
+func stageTemperature(t *Task) error {
+	l := t.Landscape
+	temp := l.layerOrBlank(LayerTemperature)
+	err := determineTemp(t.tracker, t.Rand, l.height, temp, t.Config.Sea.SeaLevel, t.Config.Temp)
+	if err != nil {
+		return err
+	}
+	return moderateCoasts(t.tracker, l.sea, l.layerOrBlank(LayerCurrentTemp), temp, t.Config.Ocean)
+}
+
+func stageRainfall(t *Task) error {
+	l := t.Landscape
+	return determineRainfall(t.tracker, t.Rand, l.height, l.sea, l.layerOrBlank(LayerRainfall), t.Config.Sea.SeaLevel, t.Config.Rain)
+}
+
+func stageBiomes(t *Task) error {
+	return t.Landscape.determineBiomes(t.tracker, t.Config)
+}
+
+func stageEcotones(t *Task) error {
+	l := t.Landscape
+	secondary, confidence, edge, err := determineEcotones(t.tracker, l.layerOrBlank(LayerBiomes), t.Config.Biome.EcotoneRadius)
+	if err != nil {
+		return err
+	}
+	l.biomeSecondary = secondary
+	l.biomeConfidence = confidence
+	l.biomeEdge = edge
+	return nil
+}
+
+func stageVegetation(t *Task) error {
+	veg, err := t.Landscape.determineVegetation(t.tracker, t.Config)
+	if err != nil {
+		return err
+	}
+	t.Landscape.vegetation = veg
+	return nil
+}
+
+func stageSoil(t *Task) error {
+	soil, fertility, err := t.Landscape.determineSoil(t.tracker, t.Config)
+	if err != nil {
+		return err
+	}
+	t.Landscape.soil = soil
+	t.Landscape.fertility = fertility
+	return nil
+}
+
+func stageGeology(t *Task) error {
+	geology, pois, err := t.Landscape.determineGeology(t.tracker, t.Rand, t.Config)
+	if err != nil {
+		return err
+	}
+	t.Landscape.geology = geology
+	t.Landscape.AddPointsOfInterest(pois...)
+	return nil
 }
@@ -0,0 +1,141 @@
+package landscape
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata/")
+
+// goldenSeed is the seed our golden files in testdata/ are generated with
+const goldenSeed = 22
+
+// seed lets us check that behaviour holds for landscapes other than the golden
+// one, eg. go test -seed 7
+var seed = flag.Int64("seed", goldenSeed, "seed for test landscapes")
+
+// testConfig returns a small config, so tests run quickly
+func testConfig() *Config {
+	cfg := DefaultConfig()
+	cfg.Seed = *seed
+	cfg.Width = 200
+	cfg.Height = 200
+	cfg.Rivers.Number = 10
+	cfg.Rivers.OriginMinDist = 20
+	cfg.Erosion.Iterations = 4000
+	return cfg
+}
+
+func layers(l *Landscape) map[string]image.Image {
+	return map[string]image.Image{
+		"height":         l.height,
+		"sea":            l.sea,
+		"rivers":         l.rivers,
+		"temperature":    l.temperature,
+		"rainfall":       l.rainfall,
+		"volcanism":      l.volcanic,
+		"swamp":          l.swamp,
+		"biomes":         l.biomes,
+		"currents":       l.currents,
+		"currenttemp":    l.currentTemp,
+		"continentality": l.continentality,
+
+		"biome-secondary":  l.biomeSecondary,
+		"biome-confidence": l.biomeConfidence,
+		"biome-edge":       l.biomeEdge,
+		"vegetation":       l.vegetation,
+		"geology":          l.geology,
+		"soil":             l.soil,
+		"fertility":        l.fertility,
+		"tectonics":        l.tectonics,
+	}
+}
+
+func assertSameImage(t *testing.T, name string, expect, got image.Image) {
+	assert.Equal(t, expect.Bounds(), got.Bounds(), name)
+
+	rect := expect.Bounds()
+	for dx := rect.Min.X; dx < rect.Max.X; dx++ {
+		for dy := rect.Min.Y; dy < rect.Max.Y; dy++ {
+			er, eg, eb, ea := expect.At(dx, dy).RGBA()
+			gr, gg, gb, ga := got.At(dx, dy).RGBA()
+			if er != gr || eg != gg || eb != gb || ea != ga {
+				t.Errorf("%s differs at (%d,%d)", name, dx, dy)
+				return
+			}
+		}
+	}
+}
+
+func TestPerlinLandscapeDeterministic(t *testing.T) {
+	a, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	b, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	la := layers(a)
+	lb := layers(b)
+	for name, im := range la {
+		assertSameImage(t, name, im, lb[name])
+	}
+	assert.Equal(t, len(a.rivermaps), len(b.rivermaps))
+	assert.Equal(t, a.PointsOfInterest(), b.PointsOfInterest())
+}
+
+func TestPerlinLandscapeGolden(t *testing.T) {
+	if *seed != goldenSeed {
+		t.Skip("golden files are for seed", goldenSeed)
+	}
+
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	for name, im := range layers(l) {
+		path := filepath.Join("testdata", "golden", name+".png")
+
+		if *update {
+			assert.Nil(t, savePng(path, im))
+			continue
+		}
+
+		f, err := os.Open(path)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		expect, err := png.Decode(f)
+		f.Close()
+		assert.Nil(t, err)
+
+		assertSameImage(t, name, expect, im)
+	}
+}
+
+func TestWrapXLandscape(t *testing.T) {
+	cfg := testConfig()
+	cfg.WrapX = true
+
+	l, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+	assert.True(t, l.height.WrapsX())
+
+	// there's no seam; heights change no more over the edge of the map
+	// than they do between neighbouring columns elsewhere
+	x, y := l.Dimensions()
+	seam := 0.0
+	inner := 0.0
+	for dy := 0; dy < y; dy++ {
+		seam += math.Abs(l.height.Float(0, dy) - l.height.Float(x-1, dy))
+		for dx := 1; dx < x; dx++ {
+			inner += math.Abs(l.height.Float(dx, dy) - l.height.Float(dx-1, dy))
+		}
+	}
+	assert.True(t, seam < 3*inner/float64(x-1), seam)
+}
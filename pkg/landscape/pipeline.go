@@ -0,0 +1,274 @@
+package landscape
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+)
+
+// Stage is a single named step in a Pipeline. Each stage declares the layers
+// it reads & writes so that a pipeline can check it is runnable & run
+// independent stages concurrently.
+type Stage interface {
+	// Name of the stage, must be unique within a pipeline
+	Name() string
+
+	// Reads returns the layers this stage requires
+	Reads() []Layer
+
+	// Writes returns the layers this stage sets or modifies
+	Writes() []Layer
+
+	// Run the stage
+	Run(*Task) error
+}
+
+// Task holds everything a stage needs to run
+type Task struct {
+	*tracker
+
+	// Config the landscape is being generated with
+	Config *Config
+
+	// Landscape being generated. Stages should only touch the layers they
+	// declare.
+	Landscape *Landscape
+
+	// Rand is seeded from the Config seed & the stage name, so stages
+	// are reproducible regardless of what other stages are in the pipeline
+	Rand *rand.Rand
+}
+
+// Context returns the context the pipeline is running under
+func (t *Task) Context() context.Context {
+	return t.r.ctx
+}
+
+// funcStage is a Stage implemented by a simple func
+type funcStage struct {
+	name   string
+	reads  []Layer
+	writes []Layer
+	fn     func(*Task) error
+}
+
+// NewStage returns a Stage that calls the given func when run
+func NewStage(name string, reads, writes []Layer, fn func(*Task) error) Stage {
+	return &funcStage{name: name, reads: reads, writes: writes, fn: fn}
+}
+
+func (s *funcStage) Name() string      { return s.name }
+func (s *funcStage) Reads() []Layer    { return s.reads }
+func (s *funcStage) Writes() []Layer   { return s.writes }
+func (s *funcStage) Run(t *Task) error { return s.fn(t) }
+
+// Pipeline is an ordered set of stages that together generate a Landscape
+type Pipeline struct {
+	stages []Stage
+}
+
+// NewPipeline returns a pipeline running the given stages in order
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Stages returns the stages of the pipeline in order
+func (p *Pipeline) Stages() []Stage {
+	return append([]Stage{}, p.stages...)
+}
+
+// Append adds a stage to the end of the pipeline
+func (p *Pipeline) Append(s Stage) {
+	p.stages = append(p.stages, s)
+}
+
+// InsertBefore adds a stage before the named stage
+func (p *Pipeline) InsertBefore(name string, s Stage) error {
+	i, err := p.index(name)
+	if err != nil {
+		return err
+	}
+	p.stages = append(p.stages[:i], append([]Stage{s}, p.stages[i:]...)...)
+	return nil
+}
+
+// InsertAfter adds a stage after the named stage
+func (p *Pipeline) InsertAfter(name string, s Stage) error {
+	i, err := p.index(name)
+	if err != nil {
+		return err
+	}
+	p.stages = append(p.stages[:i+1], append([]Stage{s}, p.stages[i+1:]...)...)
+	return nil
+}
+
+// Replace swaps out the named stage for the given stage
+func (p *Pipeline) Replace(name string, s Stage) error {
+	i, err := p.index(name)
+	if err != nil {
+		return err
+	}
+	p.stages[i] = s
+	return nil
+}
+
+// Remove drops the named stage from the pipeline
+func (p *Pipeline) Remove(name string) error {
+	i, err := p.index(name)
+	if err != nil {
+		return err
+	}
+	p.stages = append(p.stages[:i], p.stages[i+1:]...)
+	return nil
+}
+
+func (p *Pipeline) index(name string) (int, error) {
+	for i, s := range p.stages {
+		if s.Name() == name {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("no stage named %s", name)
+}
+
+// Validate checks stage names are unique & that every layer a stage reads
+// is written by some earlier stage
+func (p *Pipeline) Validate() error {
+	names := map[string]bool{}
+	written := map[Layer]bool{}
+	for _, s := range p.stages {
+		if names[s.Name()] {
+			return fmt.Errorf("duplicate stage name %s", s.Name())
+		}
+		names[s.Name()] = true
+
+		for _, r := range s.Reads() {
+			if !written[r] {
+				return fmt.Errorf("stage %s reads layer %s but no earlier stage writes it", s.Name(), r)
+			}
+		}
+		for _, w := range s.Writes() {
+			written[w] = true
+		}
+	}
+	return nil
+}
+
+// Generator returns the pipeline as a Generator
+func (p *Pipeline) Generator() Generator {
+	return func(cfg *Config) (*Landscape, error) {
+		return p.Run(context.Background(), cfg, nil)
+	}
+}
+
+// Run the pipeline to generate a landscape. Generation is cancelled via the
+// given context & progress through each stage is reported to the given
+// ProgressFunc (which may be nil).
+func (p *Pipeline) Run(ctx context.Context, cfg *Config, fn ProgressFunc) (*Landscape, error) {
+	err := p.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	rep := newReporter(ctx, fn)
+	l := &Landscape{config: cfg, pointsOfInterest: []*POI{}}
+
+	for _, batch := range p.batches() {
+		err = runBatch(rep, l, cfg, batch)
+		if err != nil {
+			return nil, err
+		}
+		l.setWrapX(cfg.WrapX) // so later stages see maps that wrap
+	}
+
+	// anything no stage wrote is left blank
+	for name := range l.builtinLayers() {
+		l.layerOrBlank(name)
+	}
+	l.setWrapX(cfg.WrapX)
+	if l.network == nil {
+		l.network = &RiverNetwork{Rivers: []*River{}}
+	}
+
+	return l, nil
+}
+
+// batches splits our stages into runs of consecutive stages that can be run
+// concurrently, that is, no stage in a batch writes a layer that another
+// stage in the same batch reads or writes.
+func (p *Pipeline) batches() [][]Stage {
+	result := [][]Stage{}
+	batch := []Stage{}
+
+	conflicts := func(a, b Stage) bool {
+		for _, w := range a.Writes() {
+			for _, l := range append(b.Reads(), b.Writes()...) {
+				if w == l {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for _, s := range p.stages {
+		for _, other := range batch {
+			if conflicts(s, other) || conflicts(other, s) {
+				result = append(result, batch)
+				batch = []Stage{}
+				break
+			}
+		}
+		batch = append(batch, s)
+	}
+	if len(batch) > 0 {
+		result = append(result, batch)
+	}
+
+	return result
+}
+
+// runBatch runs a set of stages concurrently
+func runBatch(rep *reporter, l *Landscape, cfg *Config, batch []Stage) error {
+	wg := sync.WaitGroup{}
+	wg.Add(len(batch))
+	errs := make(chan error, len(batch))
+
+	for _, s := range batch {
+		go func(s Stage) {
+			defer wg.Done()
+
+			t := &Task{
+				tracker:   rep.stage(s.Name()),
+				Config:    cfg,
+				Landscape: l,
+				Rand:      rand.New(rand.NewSource(stageSeed(cfg.Seed, s.Name()))),
+			}
+
+			err := t.Err()
+			if err == nil {
+				err = s.Run(t)
+			}
+			if err != nil {
+				errs <- fmt.Errorf("%w in stage %s", err, s.Name())
+				return
+			}
+
+			t.done()
+		}(s)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// stageSeed derives the seed for a stage from the config seed
+func stageSeed(seed int64, name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return seed ^ int64(h.Sum64())
+}
@@ -0,0 +1,129 @@
+package landscape
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineCustomStage(t *testing.T) {
+	p := PerlinPipeline()
+
+	err := p.InsertAfter(StageSea, NewStage(
+		"coast",
+		[]Layer{LayerSea},
+		[]Layer{"coast"},
+		func(t *Task) error {
+			x, y := t.Landscape.Dimensions()
+			coast := NewMapImage(x, y)
+			eachPixel(t.Landscape.Layer(LayerSea), func(dx, dy int, c uint8) {
+				if c == 255 && len(pixelsBetween(0, 0, t.Landscape.Layer(LayerSea).Nearby(dx, dy, 1, false))) > 0 {
+					coast.SetValue(dx, dy, 255)
+				}
+			})
+			t.Landscape.SetLayer("coast", coast)
+			return nil
+		},
+	))
+	assert.Nil(t, err)
+
+	l, err := p.Run(context.Background(), testConfig(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []Layer{"coast"}, l.CustomLayers())
+	assert.NotNil(t, l.Layer("coast"))
+
+	// our custom stage should not change the other stages
+	expect, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+	for name, im := range layers(expect) {
+		assertSameImage(t, name, im, layers(l)[name])
+	}
+}
+
+func TestPipelineConcurrentCustomStages(t *testing.T) {
+	p := PerlinPipeline()
+
+	// stages writing different layers run in the same batch
+	for _, name := range []Layer{"a", "b", "c"} {
+		name := name
+		p.Append(NewStage(string(name), []Layer{LayerHeight}, []Layer{name}, func(t *Task) error {
+			x, y := t.Landscape.Dimensions()
+			t.Landscape.SetLayer(name, NewMapImage(x, y))
+			t.Landscape.AddPointsOfInterest(&POI{Type: PointType(name)})
+			return nil
+		}))
+	}
+	batches := p.batches()
+	last := []string{}
+	for _, s := range batches[len(batches)-1] {
+		last = append(last, s.Name())
+	}
+	assert.Subset(t, last, []string{"a", "b", "c"})
+
+	l, err := p.Run(context.Background(), testConfig(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []Layer{"a", "b", "c"}, l.CustomLayers())
+
+	found := 0
+	for _, poi := range l.PointsOfInterest() {
+		if poi.Type == "a" || poi.Type == "b" || poi.Type == "c" {
+			found++
+		}
+	}
+	assert.Equal(t, 3, found)
+}
+
+func TestPipelineValidate(t *testing.T) {
+	p := PerlinPipeline()
+	assert.Nil(t, p.Validate())
+
+	assert.Nil(t, p.Remove(StageHeightmap))
+	assert.NotNil(t, p.Validate())
+
+	p = PerlinPipeline()
+	assert.NotNil(t, p.Remove("no-such-stage"))
+	p.Append(NewStage(StageSea, nil, nil, func(*Task) error { return nil }))
+	assert.NotNil(t, p.Validate())
+}
+
+func TestPipelineRemoveStage(t *testing.T) {
+	p := PerlinPipeline()
+
+	// ecotones read biomes, so can't run without them
+	assert.Nil(t, p.Remove(StageBiomes))
+	assert.NotNil(t, p.Validate())
+	assert.Nil(t, p.Remove(StageEcotones))
+
+	l, err := p.Run(context.Background(), testConfig(), nil)
+	assert.Nil(t, err)
+
+	// missing layers are blank
+	x, y := l.Dimensions()
+	assert.NotNil(t, l.Layer(LayerBiomes))
+	assert.Equal(t, uint8(0), l.Layer(LayerBiomes).Value(x/2, y/2))
+}
+
+func TestPipelineBatches(t *testing.T) {
+	names := [][]string{}
+	for _, batch := range PerlinPipeline().batches() {
+		b := []string{}
+		for _, s := range batch {
+			b = append(b, s.Name())
+		}
+		names = append(names, b)
+	}
+	assert.Equal(t, [][]string{
+		{StageHeightmap},
+		{StageMask},
+		{StageErosion},
+		{StageThermal},
+		{StageGeothermal},
+		{StageSea},
+		{StageCurrents, StageSeasons, StageRivers},
+		{StageMountains},
+		{StageSwamp, StageTemperature, StageRainfall},
+		{StageBiomes, StageVegetation, StageGeology},
+		{StageSoil, StageEcotones},
+	}, names)
+}
@@ -36,8 +36,8 @@ func pixelsBetween(gte, lte uint8, in []*Pixel) []*Pixel {
 	return result
 }
 
-func shuffle(in []*Pixel) {
-	rand.Shuffle(len(in), func(i, j int) {
+func shuffle(rng *rand.Rand, in []*Pixel) {
+	rng.Shuffle(len(in), func(i, j int) {
 		in[i], in[j] = in[j], in[i]
 	})
 }
@@ -62,6 +62,21 @@ func eachPixel(im *MapImage, op func(dx, dy int, c uint8)) {
 	}
 }
 
+// minFloat returns the lowest (fractional) value in im of the given pixels
+func minFloat(im *MapImage, in []*Pixel) float64 {
+	if len(in) == 0 {
+		return 0
+	}
+	low := im.Float(in[0].X(), in[0].Y())
+	for _, p := range in[1:] {
+		v := im.Float(p.X(), p.Y())
+		if v < low {
+			low = v
+		}
+	}
+	return low
+}
+
 //
 func min(in []*Pixel) uint8 {
 	if len(in) == 0 {
@@ -10,6 +10,13 @@ const (
 	Volcano     PointType = "volcano"
 	Swamp       PointType = "swamp"
 	Mountain    PointType = "mountain"
+
+	IronDeposit   PointType = "iron"
+	CopperDeposit PointType = "copper"
+	GoldDeposit   PointType = "gold"
+	CoalDeposit   PointType = "coal"
+	SaltDeposit   PointType = "salt"
+	GemDeposit    PointType = "gems"
 )
 
 // POI `PointOfInterest`
@@ -17,4 +24,8 @@ type POI struct {
 	X    int
 	Y    int
 	Type PointType
+
+	// 0-1 how rich a mineral deposit is (see IronDeposit etc), 0 for
+	// anything that isn't a deposit
+	Richness float64 `json:",omitempty"`
 }
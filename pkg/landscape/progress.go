@@ -0,0 +1,91 @@
+package landscape
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Progress is reported as a landscape is generated, once at the start of each
+// stage, periodically as the stage runs & once when it completes.
+type Progress struct {
+	// name of the stage (eg. "sea", "rivers")
+	Stage string
+
+	// how far through the stage we are, 0 -> 1
+	Fraction float64
+
+	// time since the stage started
+	Elapsed time.Duration
+
+	// set once the stage has completed
+	Done bool
+}
+
+// ProgressFunc is handed Progress events during generation.
+// Some stages run concurrently, but calls are never made concurrently.
+type ProgressFunc func(*Progress)
+
+// reporter hands out trackers for each stage of generation
+type reporter struct {
+	ctx  context.Context
+	fn   ProgressFunc
+	lock *sync.Mutex
+}
+
+func newReporter(ctx context.Context, fn ProgressFunc) *reporter {
+	return &reporter{ctx: ctx, fn: fn, lock: &sync.Mutex{}}
+}
+
+// stage begins tracking a stage with the given name
+func (r *reporter) stage(name string) *tracker {
+	t := &tracker{r: r, name: name, start: time.Now(), last: -1}
+	t.send(0, false)
+	return t
+}
+
+// tracker reports the progress of a single stage & allows the stage to
+// check if it should give up
+type tracker struct {
+	r     *reporter
+	name  string
+	start time.Time
+	last  float64
+}
+
+// Err returns a non nil error if generation has been cancelled
+func (t *tracker) Err() error {
+	return t.r.ctx.Err()
+}
+
+// Progress records that the stage is `f` (0 -> 1) complete & returns
+// a non nil error if generation has been cancelled.
+// Nb. to avoid flooding the ProgressFunc we only report in steps of 1%
+func (t *tracker) Progress(f float64) error {
+	if f-t.last >= 0.01 {
+		t.send(f, false)
+	}
+	return t.Err()
+}
+
+// done records that the stage has completed
+func (t *tracker) done() {
+	t.send(1, true)
+}
+
+func (t *tracker) send(f float64, done bool) {
+	t.last = f
+	if t.r.fn == nil {
+		return
+	}
+
+	t.r.lock.Lock()
+	defer t.r.lock.Unlock()
+
+	t.r.fn(&Progress{
+		Stage:    t.name,
+		Fraction: f,
+		Elapsed:  time.Since(t.start),
+		Done:     done,
+	})
+}
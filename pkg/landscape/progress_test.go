@@ -0,0 +1,43 @@
+package landscape
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerlinLandscapeContextProgress(t *testing.T) {
+	events := []*Progress{}
+
+	l, err := PerlinLandscapeContext(context.Background(), testConfig(), func(p *Progress) {
+		events = append(events, p)
+	})
+	assert.Nil(t, err)
+	assert.NotNil(t, l)
+
+	done := map[string]bool{}
+	for _, e := range events {
+		assert.True(t, e.Fraction >= 0 && e.Fraction <= 1, e.Stage)
+		if e.Done {
+			done[e.Stage] = true
+		}
+	}
+	for _, stage := range []string{"heightmap", "mask", "erosion", "thermal", "geothermal", "sea", "currents", "seasons", "rivers", "mountains", "swamp", "temperature", "rainfall", "biomes", "vegetation", "geology", "soil", "ecotones"} {
+		assert.True(t, done[stage], stage)
+	}
+}
+
+func TestPerlinLandscapeContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	l, err := PerlinLandscapeContext(ctx, testConfig(), func(p *Progress) {
+		if p.Stage == "sea" {
+			cancel()
+		}
+	})
+
+	assert.Nil(t, l)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,533 @@
+package landscape
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// RiverMode determines how rivers are generated
+type RiverMode string
+
+const (
+	// RiverWalk rivers start at random high points & meander downhill, carving
+	// their way to the sea (or another river).
+	RiverWalk RiverMode = "walk"
+
+	// RiverFlow rivers form wherever enough water drains over the land,
+	// with lakes where water pools in depressions.
+	RiverFlow RiverMode = "flow"
+)
+
+// minLakeDepth is how far (in height units) below it's spill point a pixel
+// must be to count as lake (rather than a river running over a flat)
+const minLakeDepth = 0.5
+
+// d8 are the offsets to each of a pixel's 8 neighbours
+var d8 = [8][2]int{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+
+// flowField holds the information we need to figure out where water goes
+type flowField struct {
+	x int
+	y int
+
+	// original heights
+	height []float64
+
+	// heights with all depressions filled, so that water can always flow
+	// downhill to a sink
+	fill []float64
+
+	// index of the pixel each pixel drains into, -1 for sinks
+	down []int
+
+	// number of pixels that drain through each pixel (including itself)
+	accum []float64
+
+	// water may flow over the east-west edge of the map
+	wrapX bool
+}
+
+// flowCell is a single entry in a flowQueue
+type flowCell struct {
+	h float64
+	i int
+}
+
+// flowQueue is a min heap of pixels by height
+type flowQueue []flowCell
+
+func (q flowQueue) Len() int { return len(q) }
+func (q flowQueue) Less(i, j int) bool {
+	if q[i].h == q[j].h {
+		return q[i].i < q[j].i
+	}
+	return q[i].h < q[j].h
+}
+func (q flowQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *flowQueue) Push(x interface{}) { *q = append(*q, x.(flowCell)) }
+func (q *flowQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+func newFlowField(hmap *MapImage) *flowField {
+	x, y := hmap.Dimensions()
+	f := &flowField{
+		x:      x,
+		y:      y,
+		height: make([]float64, x*y),
+		fill:   make([]float64, x*y),
+		down:   make([]int, x*y),
+		accum:  make([]float64, x*y),
+		wrapX:  hmap.wrapX,
+	}
+	for dy := 0; dy < y; dy++ {
+		for dx := 0; dx < x; dx++ {
+			f.height[dy*x+dx] = hmap.Float(dx, dy)
+		}
+	}
+	return f
+}
+
+// neighbours calls fn for each on-map neighbour of i
+func (f *flowField) neighbours(i int, fn func(n int)) {
+	dx := i % f.x
+	dy := i / f.x
+	for _, d := range d8 {
+		nx := dx + d[0]
+		ny := dy + d[1]
+		if f.wrapX {
+			nx = wrapCoord(nx, f.x)
+		}
+		if nx < 0 || ny < 0 || nx >= f.x || ny >= f.y {
+			continue
+		}
+		fn(ny*f.x + nx)
+	}
+}
+
+// flood fills all depressions using the priority-flood algorithm
+// (Barnes, Lehman & Mulla 2014) starting from the map edges & all
+// sink pixels. Filled areas are given a tiny slope so that they
+// drain towards their spill point. If we wrap east-west only the
+// north & south edges of the map drain.
+func (f *flowField) flood(sink func(i int) bool) {
+	const epsilon = 1e-7
+
+	seen := make([]bool, len(f.height))
+	q := &flowQueue{}
+
+	for i := range f.height {
+		dx := i % f.x
+		dy := i / f.x
+		edge := dy == 0 || dy == f.y-1 || (!f.wrapX && (dx == 0 || dx == f.x-1))
+		if sink(i) || edge {
+			seen[i] = true
+			f.fill[i] = f.height[i]
+			heap.Push(q, flowCell{f.height[i], i})
+		}
+	}
+
+	for q.Len() > 0 {
+		c := heap.Pop(q).(flowCell)
+		f.neighbours(c.i, func(n int) {
+			if seen[n] {
+				return
+			}
+			seen[n] = true
+			f.fill[n] = math.Max(f.height[n], c.h+epsilon)
+			heap.Push(q, flowCell{f.fill[n], n})
+		})
+	}
+}
+
+// direct sets the D8 flow direction of each pixel; the neighbour with the
+// steepest descent on the filled surface.
+func (f *flowField) direct(sink func(i int) bool) {
+	for i := range f.fill {
+		f.down[i] = -1
+		if sink(i) {
+			continue
+		}
+
+		best := 0.0
+		f.neighbours(i, func(n int) {
+			dist := 1.0
+			if n%f.x != i%f.x && n/f.x != i/f.x {
+				dist = math.Sqrt2
+			}
+			slope := (f.fill[i] - f.fill[n]) / dist
+			if slope > best {
+				best = slope
+				f.down[i] = n
+			}
+		})
+	}
+}
+
+// accumulate sums how many pixels drain through each pixel
+func (f *flowField) accumulate() {
+	order := make([]int, len(f.fill))
+	for i := range order {
+		order[i] = i
+		f.accum[i] = 1
+	}
+	sort.SliceStable(order, func(a, b int) bool { return f.fill[order[a]] > f.fill[order[b]] })
+
+	for _, i := range order {
+		if f.down[i] >= 0 {
+			f.accum[f.down[i]] += f.accum[i]
+		}
+	}
+}
+
+// flowRiver is a single river as a path of pixel indexes from source to end
+type flowRiver struct {
+	path   []int
+	lakes  [][]int
+	joined bool
+}
+
+// determineFlowRivers determines rivers by filling depressions, working out where
+// water would flow & how much water flows over each pixel. Rivers are placed
+// wherever enough water flows & lakes where depressions fill with water.
+// The outputs match determineRivers.
+func determineFlowRivers(t *tracker, hmap, sea, volc *MapImage, cfg *riverSettings, ls *lakeSettings) (*riverResult, error) {
+	x, y := hmap.Dimensions()
+	result := newRiverResult(x, y, hmap.wrapX)
+	out := result.rivers
+
+	if cfg.Number < 1 {
+		return result, nil
+	}
+
+	// water drains into the sea & evaporates on lava
+	isSea := make([]bool, x*y)
+	isSink := make([]bool, x*y)
+	eachPixel(sea, func(dx, dy int, c uint8) {
+		isSea[dy*x+dx] = c == 255
+		isSink[dy*x+dx] = c == 255
+	})
+	eachPixel(volc, func(dx, dy int, c uint8) {
+		isSink[dy*x+dx] = isSink[dy*x+dx] || c == 255
+	})
+	sink := func(i int) bool { return isSink[i] }
+
+	f := newFlowField(hmap)
+	f.flood(sink)
+	if err := t.Progress(0.3); err != nil {
+		return nil, err
+	}
+	f.direct(sink)
+	f.accumulate()
+	if err := t.Progress(0.5); err != nil {
+		return nil, err
+	}
+
+	threshold := float64(cfg.FlowThreshold)
+	if threshold < 1 {
+		threshold = 1
+	}
+	isRiver := make([]bool, x*y)
+	for i := range isRiver {
+		isRiver[i] = f.accum[i] >= threshold && !isSea[i]
+	}
+
+	// at each confluence the main stem is the upstream river carrying the most water
+	mainUp := make([]int, x*y)
+	hasUp := make([]bool, x*y)
+	for i := range mainUp {
+		mainUp[i] = -1
+	}
+	for i, river := range isRiver {
+		d := f.down[i]
+		if !river || d < 0 || !isRiver[d] {
+			continue
+		}
+		hasUp[d] = true
+		if mainUp[d] < 0 || f.accum[i] > f.accum[mainUp[d]] {
+			mainUp[d] = i
+		}
+	}
+
+	// each source is traced downstream until we reach a sink or join a larger river
+	rivers := []*flowRiver{}
+	for i, river := range isRiver {
+		if !river || hasUp[i] {
+			continue
+		}
+		r := &flowRiver{path: []int{i}}
+		for {
+			cur := r.end()
+			d := f.down[cur]
+			if d < 0 || !isRiver[d] {
+				break
+			}
+			r.path = append(r.path, d)
+			if mainUp[d] != cur {
+				r.joined = true
+				break // we've joined another river
+			}
+		}
+		rivers = append(rivers, r)
+	}
+
+	// keep the rivers carrying the most water, so we never drop a river but keep
+	// it's tributaries
+	rankFlowRivers(f, rivers)
+	if len(rivers) > int(cfg.Number) {
+		rivers = rivers[:cfg.Number]
+	}
+	if err := t.Progress(0.6); err != nil {
+		return nil, err
+	}
+
+	// lakes are depressions that a river flows through
+	owner := make([]int, x*y) // river that flows over each pixel (index+1)
+	for ri, r := range rivers {
+		for _, i := range r.path {
+			if owner[i] == 0 {
+				owner[i] = ri + 1
+			}
+		}
+	}
+	lakes := findFlowLakes(f, isSea, owner)
+	if len(lakes) > int(ls.Number) {
+		lakes = lakes[:ls.Number]
+	}
+	for _, lake := range lakes {
+		// the lake belongs to the largest river flowing through it
+		best := 0
+		for _, i := range lake {
+			if owner[i] > 0 && (best == 0 || owner[i] < best) {
+				best = owner[i]
+			}
+		}
+		r := rivers[best-1]
+		if len(r.lakes) < 254 {
+			r.lakes = append(r.lakes, lake)
+		}
+	}
+	if err := t.Progress(0.7); err != nil {
+		return nil, err
+	}
+
+	// finally draw everything in
+	water := []int{}
+	for ri, r := range rivers {
+		if err := t.Progress(0.7 + 0.2*float64(ri)/float64(len(rivers))); err != nil {
+			return nil, err
+		}
+
+		rvr := newRiverMap(x, y)
+		rvr.wrapX = hmap.wrapX
+		pts := []*RiverPoint{}
+
+		for j, i := range r.path {
+			dx := i % x
+			dy := i / x
+
+			pixels, width := f.riverWidth(i, threshold, cfg.FlowWidth)
+			if j > 0 {
+				// if going diagonally, we'll add a pixel of thickness
+				// so our river isn't blocky
+				prev := r.path[j-1]
+				if prev%x != dx && prev/x != dy {
+					pixels = append(pixels, dy*x+prev%x)
+				}
+			}
+
+			for _, p := range pixels {
+				if isSea[p] {
+					continue
+				}
+				rvr.SetValue(p%x, p/x, 255)
+				out.SetValue(p%x, p/x, 255)
+				water = append(water, p)
+			}
+
+			// carve out a riverbed
+			hmap.SetFloat(dx, dy, decrementFloat(hmap.Float(dx, dy), 1))
+			pts = append(pts, &RiverPoint{X: dx, Y: dy, Height: hmap.Float(dx, dy), Width: width})
+		}
+
+		river := result.network.add(hmap, pts)
+
+		for k, lake := range r.lakes {
+			deepest := lake[0]
+			for _, i := range lake {
+				rvr.SetValue(i%x, i/x, uint8(k+1))
+				out.SetValue(i%x, i/x, 255)
+				water = append(water, i)
+				if f.fill[i]-f.height[i] > f.fill[deepest]-f.height[deepest] {
+					deepest = i
+				}
+			}
+			result.pois = append(result.pois, &POI{X: deepest % x, Y: deepest / x, Type: LakeOrigin})
+			river.Lakes = append(river.Lakes, k+1)
+		}
+
+		start := r.path[0]
+		end := r.end()
+		if r.joined {
+			// rivers are sorted, so the river we join is always added before us.
+			// Nb. we end on a pixel of it's path
+			at := -1
+			for j, i := range rivers[owner[end]-1].path {
+				if i == end {
+					at = j
+					break
+				}
+			}
+			result.network.join(river, owner[end], at)
+		}
+
+		result.pois = append(
+			result.pois,
+			&POI{X: start % x, Y: start / x, Type: RiverOrigin},
+			&POI{X: end % x, Y: end / x, Type: RiverEnd},
+		)
+		result.rivermaps = append(result.rivermaps, rvr)
+	}
+
+	// up rain / fresh water map since .. there's fresh water
+	freshWater(result.rain, water, 10, 50)
+
+	result.network.finalise()
+	return result, nil
+}
+
+// rankFlowRivers sorts rivers by how much water they carry at their end, most
+// first. A river always carries at least as much water as any river joining it,
+// but a tributary joining at the very end of a river carries as much, so on a
+// tie rivers that don't join another come first, then the longest.
+func rankFlowRivers(f *flowField, rivers []*flowRiver) {
+	sort.SliceStable(rivers, func(a, b int) bool {
+		ra, rb := rivers[a], rivers[b]
+		wa, wb := f.accum[ra.end()], f.accum[rb.end()]
+		if wa != wb {
+			return wa > wb
+		}
+		if ra.joined != rb.joined {
+			return !ra.joined
+		}
+		return len(ra.path) > len(rb.path)
+	})
+}
+
+// end returns the last pixel of the river
+func (r *flowRiver) end() int {
+	return r.path[len(r.path)-1]
+}
+
+// riverWidth returns the pixels about i that make up the river & the river width,
+// where the width of the river is scaled by how much water flows through i
+func (f *flowField) riverWidth(i int, threshold, scale float64) ([]int, int) {
+	radius := int(scale * math.Sqrt(f.accum[i]/threshold) / 2)
+	if radius < 1 {
+		return []int{i}, 1
+	}
+
+	dx := i % f.x
+	dy := i / f.x
+	pixels := []int{}
+	for iy := dy - radius; iy <= dy+radius; iy++ {
+		for ix := dx - radius; ix <= dx+radius; ix++ {
+			px := ix
+			if f.wrapX {
+				px = wrapCoord(ix, f.x)
+			}
+			if px < 0 || iy < 0 || px >= f.x || iy >= f.y {
+				continue
+			}
+			if (ix-dx)*(ix-dx)+(iy-dy)*(iy-dy) > radius*radius {
+				continue
+			}
+			pixels = append(pixels, iy*f.x+px)
+		}
+	}
+	return pixels, radius*2 + 1
+}
+
+// findFlowLakes returns connected regions of filled depressions that have a
+// river flowing through them, largest first
+func findFlowLakes(f *flowField, isSea []bool, owner []int) [][]int {
+	seen := make([]bool, len(f.fill))
+	isLake := func(i int) bool {
+		return !isSea[i] && f.fill[i]-f.height[i] >= minLakeDepth
+	}
+
+	lakes := [][]int{}
+	for i := range f.fill {
+		if seen[i] || !isLake(i) {
+			continue
+		}
+
+		lake := []int{}
+		river := false
+		check := []int{i}
+		seen[i] = true
+		for len(check) > 0 {
+			me := check[len(check)-1]
+			check = check[:len(check)-1]
+			lake = append(lake, me)
+			if owner[me] > 0 {
+				river = true
+			}
+			f.neighbours(me, func(n int) {
+				if seen[n] || !isLake(n) {
+					return
+				}
+				seen[n] = true
+				check = append(check, n)
+			})
+		}
+
+		if river && len(lake) > 10 {
+			// if they're too small we don't count them as lakes ..
+			lakes = append(lakes, lake)
+		}
+	}
+
+	sort.SliceStable(lakes, func(a, b int) bool { return len(lakes[a]) > len(lakes[b]) })
+	return lakes
+}
+
+// freshWater sets `value` on all pixels within `radius` of the given pixels
+// (as MapImage.Nearby would)
+func freshWater(rain *MapImage, sources []int, radius, value uint8) {
+	x, y := rain.Dimensions()
+	dist := make([]int, x*y)
+	for i := range dist {
+		dist[i] = -1
+	}
+
+	check := []int{}
+	for _, i := range sources {
+		if dist[i] < 0 {
+			dist[i] = 0
+			check = append(check, i)
+		}
+	}
+
+	// breadth first, so each pixel is reached by the shortest route
+	for len(check) > 0 {
+		me := check[0]
+		check = check[1:]
+		rain.SetValue(me%x, me/x, value)
+		if dist[me] >= int(radius) {
+			continue
+		}
+		for _, d := range d8 {
+			n, ok := rain.neighbour(me, d)
+			if !ok || dist[n] >= 0 {
+				continue
+			}
+			dist[n] = dist[me] + 1
+			check = append(check, n)
+		}
+	}
+}
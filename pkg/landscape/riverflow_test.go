@@ -0,0 +1,123 @@
+package landscape
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlowFieldFlood(t *testing.T) {
+	// a slope down to the west with a pit in the middle
+	hmap := NewMapImage(20, 20)
+	eachPixel(hmap, func(dx, dy int, _ uint8) {
+		hmap.SetValue(dx, dy, uint8(100+dx*2))
+	})
+	hmap.SetValue(10, 10, 50)
+
+	noSink := func(int) bool { return false }
+
+	f := newFlowField(hmap)
+	f.flood(noSink)
+	f.direct(noSink)
+	f.accumulate()
+
+	pit := 10*20 + 10
+	assert.True(t, f.fill[pit] > f.height[pit])
+	assert.True(t, f.fill[pit]-f.height[pit] >= minLakeDepth)
+
+	// everything off the edge drains somewhere
+	for i := range f.fill {
+		dx := i % 20
+		dy := i / 20
+		if dx == 0 || dy == 0 || dx == 19 || dy == 19 {
+			continue
+		}
+		assert.True(t, f.down[i] >= 0)
+		assert.True(t, f.fill[f.down[i]] < f.fill[i])
+	}
+
+	// water flows west
+	assert.True(t, f.accum[10*20+1] > f.accum[10*20+18])
+}
+
+func TestFlowFieldWrapX(t *testing.T) {
+	// a valley running south along the east edge of the map
+	hmap := NewMapImage(20, 20)
+	hmap.wrapX = true
+	eachPixel(hmap, func(dx, dy int, _ uint8) {
+		if dx == 19 {
+			hmap.SetValue(dx, dy, uint8(200-dy))
+		} else {
+			hmap.SetValue(dx, dy, uint8(220-dy))
+		}
+	})
+
+	noSink := func(int) bool { return false }
+
+	f := newFlowField(hmap)
+	f.flood(noSink)
+	f.direct(noSink)
+
+	// water on the west edge flows over the edge into the valley
+	assert.Equal(t, 10*20+19, f.down[10*20])
+
+	// only the north & south edges drain off the map
+	for i := range f.fill {
+		if i/20 == 19 {
+			continue
+		}
+		assert.True(t, f.down[i] >= 0)
+	}
+}
+
+func TestDetermineFlowRivers(t *testing.T) {
+	cfg := testConfig()
+	cfg.Rivers.Mode = RiverFlow
+	cfg.Rivers.FlowThreshold = 100
+
+	l, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+	assert.True(t, len(l.rivermaps) > 0)
+
+	origins := 0
+	for _, p := range l.PointsOfInterest() {
+		switch p.Type {
+		case RiverOrigin:
+			origins++
+			assert.True(t, l.At(p.X, p.Y).River)
+		case RiverEnd:
+			// rivers end at the map edge, the sea, lava or another river
+			x, y := l.Dimensions()
+			edge := p.X == 0 || p.Y == 0 || p.X == x-1 || p.Y == y-1
+			sea := len(pixelsBetween(255, 255, l.sea.Nearby(p.X, p.Y, 1, true))) > 0
+			lava := len(pixelsBetween(255, 255, l.volcanic.Nearby(p.X, p.Y, 1, true))) > 0
+			joined := 0
+			for _, rvr := range l.rivermaps {
+				if rvr.Value(p.X, p.Y) != 0 {
+					joined++
+				}
+			}
+			river := joined > 1
+			assert.True(t, edge || sea || lava || river, p)
+		}
+	}
+	assert.Equal(t, len(l.rivermaps), origins)
+
+	again, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+	assertSameImage(t, "rivers", l.rivers, again.rivers)
+}
+
+func TestRankFlowRivers(t *testing.T) {
+	f := &flowField{accum: []float64{1, 5, 9, 9, 2}}
+
+	main := &flowRiver{path: []int{0, 3}}
+	tributary := &flowRiver{path: []int{4, 3}, joined: true}
+	longer := &flowRiver{path: []int{1, 4, 2}}
+	small := &flowRiver{path: []int{1}}
+
+	// the tributary ends where the main stem does, so carries as much water
+	rivers := []*flowRiver{small, tributary, main, longer}
+	rankFlowRivers(f, rivers)
+	assert.Equal(t, []*flowRiver{longer, main, tributary, small}, rivers)
+}
@@ -0,0 +1,180 @@
+package landscape
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// riverMap is a sparse map of the pixels of a single river, where 255 => river
+// and 1-254 => lake (by lake ID). This replaces a full sized image per river.
+type riverMap struct {
+	x   int
+	y   int
+	pix map[int]uint8
+
+	// if set the map wraps east-west (as MapImage)
+	wrapX bool
+}
+
+func newRiverMap(x, y int) *riverMap {
+	return &riverMap{x: x, y: y, pix: map[int]uint8{}}
+}
+
+// wrap returns x moved onto the map if we wrap east-west
+func (r *riverMap) wrap(x int) int {
+	if r.wrapX && (x < 0 || x >= r.x) {
+		x = wrapCoord(x, r.x)
+	}
+	return x
+}
+
+// Value returns the value at x,y (0 if not river or off the map)
+func (r *riverMap) Value(x, y int) uint8 {
+	x = r.wrap(x)
+	if x < 0 || y < 0 || x >= r.x || y >= r.y {
+		return 0
+	}
+	return r.pix[y*r.x+x]
+}
+
+// SetValue sets the value at x,y (ignoring pixels off the map)
+func (r *riverMap) SetValue(x, y int, v uint8) {
+	x = r.wrap(x)
+	if x < 0 || y < 0 || x >= r.x || y >= r.y {
+		return
+	}
+	if v == 0 {
+		delete(r.pix, y*r.x+x)
+		return
+	}
+	r.pix[y*r.x+x] = v
+}
+
+// Nearby returns all pixels nearby dx,dy within some radius (as MapImage.Nearby).
+func (r *riverMap) Nearby(dx, dy, radius int, inclusive bool) []*Pixel {
+	return nearby(r.x, r.y, dx, dy, radius, inclusive, r.wrapX, r.Value)
+}
+
+// indexes returns the pixel indexes (y * width + x) of the river in order
+func (r *riverMap) indexes() []int {
+	idx := make([]int, 0, len(r.pix))
+	for i := range r.pix {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	return idx
+}
+
+// Image draws the river out as a full sized image
+func (r *riverMap) Image() *MapImage {
+	im := NewMapImage(r.x, r.y)
+	for i, v := range r.pix {
+		im.SetValue(i%r.x, i/r.x, v)
+	}
+	return im
+}
+
+// riverMapFromImage builds a riverMap from a full sized image
+func riverMapFromImage(im *MapImage) *riverMap {
+	x, y := im.Dimensions()
+	r := newRiverMap(x, y)
+	eachPixel(im, func(dx, dy int, v uint8) {
+		r.SetValue(dx, dy, v)
+	})
+	return r
+}
+
+// encodeRiverMaps writes out the pixels of each river as
+// [uint32 count] then [uint32 index, uint8 value] * count (little endian)
+func encodeRiverMaps(rivers []*riverMap) []byte {
+	buff := new(bytes.Buffer)
+	for _, r := range rivers {
+		idx := r.indexes()
+		binary.Write(buff, binary.LittleEndian, uint32(len(idx)))
+		for _, i := range idx {
+			binary.Write(buff, binary.LittleEndian, uint32(i))
+			buff.WriteByte(r.pix[i])
+		}
+	}
+	return buff.Bytes()
+}
+
+// decodeRiverMaps reads rivers written by encodeRiverMaps
+func decodeRiverMaps(x, y, number int, data []byte) ([]*riverMap, error) {
+	buff := bytes.NewReader(data)
+	rivers := []*riverMap{}
+
+	for n := 0; n < number; n++ {
+		var count uint32
+		err := binary.Read(buff, binary.LittleEndian, &count)
+		if err != nil {
+			return nil, fmt.Errorf("%w reading river %d", err, n)
+		}
+
+		r := newRiverMap(x, y)
+		for c := uint32(0); c < count; c++ {
+			var i uint32
+			err = binary.Read(buff, binary.LittleEndian, &i)
+			if err != nil {
+				return nil, fmt.Errorf("%w reading river %d", err, n)
+			}
+			v, err := buff.ReadByte()
+			if err != nil {
+				return nil, fmt.Errorf("%w reading river %d", err, n)
+			}
+			if int(i) >= x*y {
+				return nil, fmt.Errorf("river %d pixel %d is off the map", n, i)
+			}
+			r.pix[int(i)] = v
+		}
+		rivers = append(rivers, r)
+	}
+
+	return rivers, nil
+}
+
+// riverIDs records which river & lake is at each pixel
+type riverIDs struct {
+	x int
+	y int
+
+	// river ID at each pixel (0 => no river)
+	rivers []uint16
+
+	// lake ID at each pixel (0 => no lake), lakes are numbered per river
+	lakes []uint8
+}
+
+// newRiverIDs builds our ID layers from each river. Where rivers overlap we
+// record the first river with a lake there, else the last river.
+func newRiverIDs(x, y int, rivers []*riverMap) *riverIDs {
+	ids := &riverIDs{
+		x:      x,
+		y:      y,
+		rivers: make([]uint16, x*y),
+		lakes:  make([]uint8, x*y),
+	}
+
+	for r, rvr := range rivers {
+		for _, i := range rvr.indexes() {
+			v := rvr.pix[i]
+			if ids.lakes[i] != 0 {
+				continue
+			}
+			ids.rivers[i] = uint16(r + 1)
+			if v != 255 {
+				ids.lakes[i] = v
+			}
+		}
+	}
+
+	return ids
+}
+
+// At returns the river ID & lake ID at x,y
+func (r *riverIDs) At(x, y int) (int, int) {
+	i := y*r.x + x
+	return int(r.rivers[i]), int(r.lakes[i])
+}
@@ -0,0 +1,97 @@
+package landscape
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRiverIDs(t *testing.T) {
+	a := newRiverMap(4, 4)
+	a.SetValue(0, 0, 255)
+	a.SetValue(1, 1, 255)
+	a.SetValue(2, 2, 1)
+	a.SetValue(5, 5, 255) // off the map
+
+	b := newRiverMap(4, 4)
+	b.SetValue(1, 1, 2) // lake over a's river
+	b.SetValue(2, 2, 3) // lake over a's lake
+	b.SetValue(3, 3, 255)
+	b.SetValue(0, 0, 255) // river over a's river
+
+	ids := newRiverIDs(4, 4, []*riverMap{a, b})
+
+	for _, tt := range []struct {
+		X, Y  int
+		River int
+		Lake  int
+	}{
+		{0, 0, 2, 0},
+		{1, 1, 2, 2},
+		{2, 2, 1, 1},
+		{3, 3, 2, 0},
+		{0, 3, 0, 0},
+	} {
+		river, lake := ids.At(tt.X, tt.Y)
+		assert.Equal(t, tt.River, river, "river at %d,%d", tt.X, tt.Y)
+		assert.Equal(t, tt.Lake, lake, "lake at %d,%d", tt.X, tt.Y)
+	}
+
+	// pixels off the map aren't the next or last row
+	a.SetValue(0, 2, 255)
+	a.SetValue(3, 0, 255)
+	assert.Equal(t, uint8(0), a.Value(4, 1))
+	assert.Equal(t, uint8(0), a.Value(-1, 1))
+	assert.Equal(t, uint8(0), a.Value(0, -1))
+	a.SetValue(0, 2, 0)
+	a.SetValue(3, 0, 0)
+
+	assert.Equal(t, 3, len(a.pix))
+	assert.Equal(t, a.pix, riverMapFromImage(a.Image()).pix)
+}
+
+func TestEncodeRiverMaps(t *testing.T) {
+	a := newRiverMap(10, 10)
+	a.SetValue(0, 0, 255)
+	a.SetValue(9, 9, 4)
+	b := newRiverMap(10, 10)
+	c := newRiverMap(10, 10)
+	c.SetValue(3, 7, 255)
+
+	data := encodeRiverMaps([]*riverMap{a, b, c})
+
+	result, err := decodeRiverMaps(10, 10, 3, data)
+	assert.Nil(t, err)
+	assert.Equal(t, []*riverMap{a, b, c}, result)
+
+	_, err = decodeRiverMaps(10, 10, 3, data[:len(data)-1])
+	assert.NotNil(t, err)
+
+	_, err = decodeRiverMaps(5, 5, 3, data)
+	assert.NotNil(t, err)
+}
+
+func TestRiverAt(t *testing.T) {
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	x, y := l.Dimensions()
+	for id := 1; id <= len(l.rivermaps); id++ {
+		im := l.RiverMap(id)
+		eachPixel(im, func(dx, dy int, v uint8) {
+			if v == 0 {
+				return
+			}
+			a := l.RiverAt(dx, dy)
+			assert.True(t, a.River)
+			assert.NotEqual(t, 0, a.RiverID)
+		})
+	}
+	assert.Nil(t, l.RiverMap(0))
+	assert.Nil(t, l.RiverMap(len(l.rivermaps)+1))
+
+	a := l.RiverAt(x-1, y-1)
+	if !a.River {
+		assert.Equal(t, 0, a.RiverID)
+	}
+}
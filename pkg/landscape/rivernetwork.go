@@ -0,0 +1,216 @@
+package landscape
+
+import (
+	"sort"
+
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+// RiverPoint is a single point along a river
+type RiverPoint struct {
+	X int
+	Y int
+
+	// height of the riverbed (0-255, fractional)
+	Height float64
+
+	// approximate width of the river (in pixels) at this point
+	Width int
+}
+
+// River is a single river, flowing from it's source to it's mouth.
+type River struct {
+	// ID of the river, as given by Area.RiverID
+	ID int
+
+	// Points along the river, in order from source to mouth (ie. downstream)
+	Points []*RiverPoint
+
+	// ID of the river this river flows into, 0 if it flows into
+	// the sea, a lava field or off the map
+	Joins int
+
+	// index into the joined river's Points where we join it, -1 if
+	// we don't join another river
+	JoinsAt int
+
+	// IDs of rivers that flow into this one
+	Tributaries []int
+
+	// IDs of the lakes on this river (as given by Area.LakeID)
+	Lakes []int
+
+	// Strahler stream order of the river (at it's mouth), where a river with no
+	// tributaries is 1, two order 1 rivers joining form an order 2 river etc.
+	Order int
+}
+
+// Source returns the first point of the river
+func (r *River) Source() *RiverPoint {
+	return r.Points[0]
+}
+
+// Mouth returns the last point of the river. This is where it enters the sea
+// or joins another river.
+func (r *River) Mouth() *RiverPoint {
+	return r.Points[len(r.Points)-1]
+}
+
+// Heading returns the direction the river is flowing at the i-th point
+func (r *River) Heading(i int) shapes.Heading {
+	if len(r.Points) < 2 {
+		return shapes.NORTH
+	}
+	if i >= len(r.Points)-1 {
+		i = len(r.Points) - 2
+	}
+	a := r.Points[i]
+	b := r.Points[i+1]
+	return toHeading(b.X-a.X, b.Y-a.Y)
+}
+
+// Confluence is a place where a tributary joins a river
+type Confluence struct {
+	X int
+	Y int
+
+	// ID of the river being joined
+	River int
+
+	// ID of the joining river
+	Tributary int
+}
+
+// RiverNetwork holds all of the rivers on a map
+type RiverNetwork struct {
+	Rivers []*River
+}
+
+// Rivers returns the river network of the landscape
+func (l *Landscape) Rivers() *RiverNetwork {
+	return l.network
+}
+
+// River returns the river with the given ID, or nil
+func (n *RiverNetwork) River(id int) *River {
+	if id < 1 || id > len(n.Rivers) {
+		return nil
+	}
+	return n.Rivers[id-1]
+}
+
+// Confluences returns all the places rivers join
+func (n *RiverNetwork) Confluences() []*Confluence {
+	result := []*Confluence{}
+	for _, r := range n.Rivers {
+		joined := n.River(r.Joins)
+		if joined == nil || r.JoinsAt < 0 {
+			continue
+		}
+		pt := joined.Points[r.JoinsAt]
+		result = append(result, &Confluence{X: pt.X, Y: pt.Y, River: joined.ID, Tributary: r.ID})
+	}
+	return result
+}
+
+// add appends a new river with the given points (dropping any that are off the map).
+// The river is given the next river ID.
+func (n *RiverNetwork) add(hmap *MapImage, pts []*RiverPoint) *River {
+	x, y := hmap.Dimensions()
+
+	r := &River{
+		ID:          len(n.Rivers) + 1,
+		Points:      []*RiverPoint{},
+		JoinsAt:     -1,
+		Tributaries: []int{},
+		Lakes:       []int{},
+	}
+	for _, p := range pts {
+		if p.X < 0 || p.Y < 0 || p.X >= x || p.Y >= y {
+			continue
+		}
+		r.Points = append(r.Points, p)
+	}
+
+	n.Rivers = append(n.Rivers, r)
+	return r
+}
+
+// join records that river `r` flows into river `id` at it's `at`-th point
+func (n *RiverNetwork) join(r *River, id, at int) {
+	other := n.River(id)
+	if other == nil || other == r || at < 0 || at >= len(other.Points) {
+		return
+	}
+	r.Joins = id
+	r.JoinsAt = at
+}
+
+// finalise sets tributaries & stream orders now that all rivers are known
+func (n *RiverNetwork) finalise() {
+	for _, r := range n.Rivers {
+		r.Tributaries = []int{}
+	}
+	for _, r := range n.Rivers {
+		joined := n.River(r.Joins)
+		if joined != nil {
+			joined.Tributaries = append(joined.Tributaries, r.ID)
+		}
+	}
+
+	orders := map[int]int{}
+	for _, r := range n.Rivers {
+		r.Order = n.strahler(r, orders)
+	}
+}
+
+// strahler returns the stream order of the river at it's mouth
+func (n *RiverNetwork) strahler(r *River, orders map[int]int) int {
+	order, ok := orders[r.ID]
+	if ok {
+		return order
+	}
+	orders[r.ID] = 1 // guard against (impossible) loops
+
+	// walk down the river, considering tributaries as they join
+	tribs := []*River{}
+	for _, id := range r.Tributaries {
+		tribs = append(tribs, n.River(id))
+	}
+	sort.SliceStable(tribs, func(i, j int) bool { return tribs[i].JoinsAt < tribs[j].JoinsAt })
+
+	order = 1
+	for _, t := range tribs {
+		o := n.strahler(t, orders)
+		if o > order {
+			order = o
+		} else if o == order {
+			order++
+		}
+	}
+
+	orders[r.ID] = order
+	return order
+}
+
+// toHeading returns the heading of the given rise / run
+func toHeading(dx, dy int) shapes.Heading {
+	sign := func(v int) int {
+		if v < 0 {
+			return -1
+		} else if v > 0 {
+			return 1
+		}
+		return 0
+	}
+	dx = sign(dx)
+	dy = sign(dy)
+	for i := 0; i < 8; i++ {
+		h := shapes.ToHeadingInt(i)
+		hx, hy := h.RiseRun()
+		if hx == dx && hy == dy {
+			return h
+		}
+	}
+	return shapes.NORTH
+}
@@ -0,0 +1,143 @@
+package landscape
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertRiverNetwork(t *testing.T, l *Landscape) {
+	n := l.Rivers()
+	assert.Equal(t, len(l.rivermaps), len(n.Rivers))
+
+	for i, r := range n.Rivers {
+		assert.Equal(t, i+1, r.ID)
+		if !assert.True(t, len(r.Points) > 0) {
+			continue
+		}
+		assert.NotEqual(t, uint8(0), l.rivermaps[i].Value(r.Source().X, r.Source().Y))
+		assert.NotEqual(t, uint8(0), l.rivermaps[i].Value(r.Mouth().X, r.Mouth().Y))
+		assert.True(t, r.Order >= 1)
+
+		// each point follows on from the last
+		for j := 1; j < len(r.Points); j++ {
+			a := r.Points[j-1]
+			b := r.Points[j]
+			assert.True(t, abs(a.X-b.X) <= 1 && abs(a.Y-b.Y) <= 1)
+		}
+
+		if r.Joins == 0 {
+			assert.Equal(t, -1, r.JoinsAt)
+			continue
+		}
+
+		joined := n.River(r.Joins)
+		assert.Contains(t, joined.Tributaries, r.ID)
+		assert.True(t, joined.Order >= r.Order)
+
+		// we end near where we join
+		at := joined.Points[r.JoinsAt]
+		assert.True(t, abs(at.X-r.Mouth().X) <= 2 && abs(at.Y-r.Mouth().Y) <= 2, r.ID)
+	}
+
+	for _, c := range n.Confluences() {
+		assert.Equal(t, c.River, n.River(c.Tributary).Joins)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func TestRiverNetworkWalk(t *testing.T) {
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+	assertRiverNetwork(t, l)
+}
+
+func TestRiverNetworkFlow(t *testing.T) {
+	cfg := testConfig()
+	cfg.Rivers.Mode = RiverFlow
+	cfg.Rivers.FlowThreshold = 100
+
+	l, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+	assertRiverNetwork(t, l)
+
+	// with a low threshold we expect some tributaries
+	assert.True(t, len(l.Rivers().Confluences()) > 0)
+}
+
+func TestRiverNetworkStrahler(t *testing.T) {
+	pt := func(x int) []*RiverPoint {
+		pts := []*RiverPoint{}
+		for i := 0; i < 10; i++ {
+			pts = append(pts, &RiverPoint{X: x, Y: i})
+		}
+		return pts
+	}
+	hmap := NewMapImage(10, 10)
+
+	n := &RiverNetwork{}
+	main := n.add(hmap, pt(0))
+	a := n.add(hmap, pt(1))
+	b := n.add(hmap, pt(2))
+	c := n.add(hmap, pt(3))
+	d := n.add(hmap, pt(4))
+
+	// a & b (order 1) join c making it order 2, c joins main
+	// d (order 1) also joins main, after c
+	n.join(a, c.ID, 2)
+	n.join(b, c.ID, 3)
+	n.join(c, main.ID, 4)
+	n.join(d, main.ID, 6)
+	n.finalise()
+
+	assert.Equal(t, 1, a.Order)
+	assert.Equal(t, 1, b.Order)
+	assert.Equal(t, 2, c.Order)
+	assert.Equal(t, 1, d.Order)
+	assert.Equal(t, 2, main.Order)
+	assert.Equal(t, []int{c.ID, d.ID}, main.Tributaries)
+	assert.Equal(t, 4, c.JoinsAt)
+	assert.Equal(t, 2, a.JoinsAt)
+}
+
+func TestMergePath(t *testing.T) {
+	// a river flowing east along y=2, with a lake to the south of it
+	other := newRiverMap(10, 10)
+	pts := []*RiverPoint{}
+	for dx := 0; dx < 10; dx++ {
+		other.SetValue(dx, 2, 255)
+		pts = append(pts, &RiverPoint{X: dx, Y: 2})
+	}
+	for dx := 4; dx < 7; dx++ {
+		for dy := 3; dy < 6; dy++ {
+			other.SetValue(dx, dy, 1)
+		}
+	}
+	n := &RiverNetwork{}
+	joined := n.add(NewMapImage(10, 10), pts)
+
+	// merging onto the river itself
+	through, at := mergePath(other, joined, pix(7, 2, 255))
+	assert.Empty(t, through)
+	assert.Equal(t, 7, at)
+
+	// merging into the lake, we cross it to reach the river
+	through, at = mergePath(other, joined, pix(5, 5, 1))
+	assert.Equal(t, 3, len(through))
+	assert.Equal(t, 5, through[0].X())
+	assert.Equal(t, 5, through[0].Y())
+	last := through[len(through)-1]
+	assert.Equal(t, 3, last.Y())
+	assert.True(t, abs(pts[at].X-last.X()) <= 1)
+
+	// not on the river at all
+	through, at = mergePath(other, joined, pix(0, 9, 255))
+	assert.Nil(t, through)
+	assert.Equal(t, -1, at)
+}
@@ -1,33 +1,61 @@
 package landscape
 
 import (
-	perlin "github.com/voidshard/cartographer/pkg/perlin"
 	"github.com/voidshard/cartographer/pkg/shapes"
 
 	"math/rand"
 	"sort"
 )
 
-// determineRivers determines where our rivers will be, we return a new heightmap
-// & the map of rivers.
-// Rivers are sufficiently complicated that they seem worth their own file ..
-func determineRivers(hmap, sea, volc *MapImage, cfg *riverSettings, ls *lakeSettings) (*MapImage, []*MapImage, *MapImage, []*POI) {
-	x, y := hmap.Dimensions()
+// riverResult holds everything output when determining rivers
+type riverResult struct {
+	// map of all fresh water
+	rivers *MapImage
+
+	// a map per river
+	rivermaps []*riverMap
+
+	// fresh water map, that is areas near rivers / lakes
+	rain *MapImage
+
+	// river origin / ends & lakes
+	pois []*POI
+
+	// each river, how they flow & join
+	network *RiverNetwork
+}
+
+func newRiverResult(x, y int, wrapX bool) *riverResult {
 	out := NewMapImage(x, y)
 	out.SetBackground(0)
+	out.wrapX = wrapX
 
-	rain := NewMapImage(x, y)
-	rain.SetBackground(0)
+	rain := NewMapImage16(x, y)
+	rain.wrapX = wrapX
 
-	rivermaps := []*MapImage{}
-	pois := []*POI{}
+	return &riverResult{
+		rivers:    out,
+		rivermaps: []*riverMap{},
+		rain:      rain,
+		pois:      []*POI{},
+		network:   &RiverNetwork{Rivers: []*River{}},
+	}
+}
+
+// determineRivers determines where our rivers will be, we return a new heightmap
+// & the map of rivers.
+// Rivers are sufficiently complicated that they seem worth their own file ..
+func determineRivers(t *tracker, rng *rand.Rand, hmap, sea, volc *MapImage, cfg *riverSettings, ls *lakeSettings) (*riverResult, error) {
+	x, y := hmap.Dimensions()
+	result := newRiverResult(x, y, hmap.wrapX)
+	out := result.rivers
 
 	if cfg.Number < 1 {
-		return out, rivermaps, rain, pois
+		return result, nil
 	}
 
-	origins := riverOrigins(hmap, cfg) // places where a river might start
-	shuffle(origins)
+	origins := riverOrigins(rng, hmap, cfg) // places where a river might start
+	shuffle(rng, origins)
 
 	rivers := 0 // rivers we've accepted
 	lakes := 0  // lakes we've added
@@ -35,33 +63,78 @@ func determineRivers(hmap, sea, volc *MapImage, cfg *riverSettings, ls *lakeSett
 	minLakeRiverLen := int(ls.MinDistFromStart) + int(ls.MinDistFromEnd)
 
 	for _, o := range origins {
+		err := t.Progress(float64(rivers) / float64(cfg.Number))
+		if err != nil {
+			return nil, err
+		}
+
 		if volc.Value(o.X(), o.Y()) > 120 {
 			// don't start rivers in volcanic land
 			continue
 		}
 
 		// draw in the river, expanding the outline (& respecting other rivers)
-		rvr, riverpois, rpath := drawRiver(hmap, out, rain, sea, volc, o, cfg)
-		if len(rpath) > minLakeRiverLen && lakes < int(ls.Number) {
-			// ie. as we get more lakes, new lakes become less likely
-			if rand.Intn(int(ls.Number)) > lakes {
-				continue
-			}
+		rvr, riverpois, rpath, into := drawRiver(rng, hmap, out, result.rain, sea, volc, o, cfg)
 
+		// ie. as we get more lakes, new lakes become less likely.
+		// nb. if we don't roll a lake we keep the river regardless; it's
+		// already been drawn into the rivers & rain maps
+		lake := false
+		if len(rpath) > minLakeRiverLen && lakes < int(ls.Number) && rng.Intn(int(ls.Number)) <= lakes {
 			// we pick a random part of the river that is not too close
 			// to the end nor the start
-			idx := rand.Intn(len(rpath)-minLakeRiverLen) + int(ls.MinDistFromStart)
+			idx := rng.Intn(len(rpath)-minLakeRiverLen) + int(ls.MinDistFromStart)
 
-			size := fillLake(hmap, sea, out, rvr, volc, rpath[idx], ls)
-			if size > 10 {
+			filled := fillLake(rng, hmap, sea, out, rvr, volc, rpath[idx], ls)
+			if len(filled) > 10 {
 				// if they're too small we don't count them as lakes ..
+				lake = true
 				lakes++
-				pois = append(pois, &POI{X: rpath[idx].X(), Y: rpath[idx].Y(), Type: LakeOrigin})
+				result.pois = append(result.pois, &POI{X: rpath[idx].X(), Y: rpath[idx].Y(), Type: LakeOrigin})
+			}
+			if lake {
+				// mark out the lake (the river is still marked through the lake)
+				for _, p := range filled {
+					rvr.SetValue(p.X(), p.Y(), 1)
+				}
 			}
 		}
 
-		pois = append(pois, riverpois...)
-		rivermaps = append(rivermaps, rvr)
+		// figure out which river we merged into & where
+		joins, joinsAt := 0, -1
+		if into != nil {
+			for i, other := range result.rivermaps {
+				if other.Value(into.X(), into.Y()) == 0 {
+					continue
+				}
+				through, at := mergePath(other, result.network.River(i+1), into)
+				if other.Value(into.X(), into.Y()) != 255 {
+					// we flowed into a lake, so we carry on through the
+					// lake until we meet the river
+					for _, p := range through {
+						rvr.SetValue(p.X(), p.Y(), 255)
+					}
+					rpath = append(rpath, through...)
+				}
+				joins, joinsAt = i+1, at
+				break
+			}
+		}
+
+		pts := []*RiverPoint{}
+		for _, p := range rpath {
+			pts = append(pts, &RiverPoint{X: p.X(), Y: p.Y(), Height: hmap.Float(p.X(), p.Y()), Width: 1})
+		}
+		river := result.network.add(hmap, pts)
+		if lake {
+			river.Lakes = append(river.Lakes, 1)
+		}
+		if joins > 0 {
+			result.network.join(river, joins, joinsAt)
+		}
+
+		result.pois = append(result.pois, riverpois...)
+		result.rivermaps = append(result.rivermaps, rvr)
 
 		rivers++
 		if rivers >= int(cfg.Number) {
@@ -69,17 +142,19 @@ func determineRivers(hmap, sea, volc *MapImage, cfg *riverSettings, ls *lakeSett
 		}
 	}
 
-	return out, rivermaps, rain, pois
+	result.network.finalise()
+	return result, nil
 }
 
 // fillLake draws in a lake given it's origin point (on some river).
 // We're allowed to touch pixels adjacent to our own river (expanding it)
 // but we can't join other rivers (because we'd then have a lake with
 // more than one exit river .. which is really weird).
-func fillLake(hmap, sea, rvrs, rvr, volc *MapImage, o *Pixel, ls *lakeSettings) int {
+// We return the pixels added to the river.
+func fillLake(rng *rand.Rand, hmap, sea, rvrs *MapImage, rvr *riverMap, volc *MapImage, o *Pixel, ls *lakeSettings) []*Pixel {
 	x, y := hmap.Dimensions()
 
-	pmap := &MapImage{im: perlin.Perlin(x, y, ls.Variance)}
+	pmap := noise(rng, x, y, ls.Variance, hmap.wrapX)
 	pv := pmap.Value(o.X(), o.Y())
 
 	pmax := increment(pv, ls.Radius)
@@ -87,10 +162,10 @@ func fillLake(hmap, sea, rvrs, rvr, volc *MapImage, o *Pixel, ls *lakeSettings)
 
 	seen := map[int]bool{}
 	check := []*Pixel{o}
-	lakeBed := hmap.Value(o.X(), o.Y())
+	lakeBed := hmap.Float(o.X(), o.Y())
 	startVolc := volc.Value(o.X(), o.Y())
 
-	size := 0
+	filled := []*Pixel{}
 
 	for {
 		if len(check) == 0 {
@@ -100,14 +175,16 @@ func fillLake(hmap, sea, rvrs, rvr, volc *MapImage, o *Pixel, ls *lakeSettings)
 		me := check[len(check)-1]
 		check = check[:len(check)-1] // slice off the last element
 
+		if rvr.Value(me.X(), me.Y()) != 255 {
+			filled = append(filled, me)
+		}
 		rvr.SetValue(me.X(), me.Y(), 255)
 		rvrs.SetValue(me.X(), me.Y(), 255)
-		size++
 
-		currentH := hmap.Value(me.X(), me.Y())
+		currentH := hmap.Float(me.X(), me.Y())
 		if currentH > lakeBed {
-			newh := decrement(currentH, uint8(rand.Intn(3)))
-			hmap.SetValue(me.X(), me.Y(), newh)
+			newh := decrementFloat(currentH, float64(rng.Intn(3)))
+			hmap.SetFloat(me.X(), me.Y(), newh)
 		} else if currentH < lakeBed {
 			lakeBed = currentH
 		}
@@ -142,7 +219,7 @@ func fillLake(hmap, sea, rvrs, rvr, volc *MapImage, o *Pixel, ls *lakeSettings)
 			}
 
 			// rather than hard stopping at the radius, we'll allow it to phase out
-			dist := o.Point.DistPt(next.Point)
+			dist := hmap.dist(o.Point, next.Point)
 			if dist > ls.HardMaxRadius {
 				continue
 			}
@@ -160,7 +237,7 @@ func fillLake(hmap, sea, rvrs, rvr, volc *MapImage, o *Pixel, ls *lakeSettings)
 		check = append(check, candidates...)
 	}
 
-	return size
+	return filled
 }
 
 type candidate struct {
@@ -172,42 +249,90 @@ func (c *candidate) Ok() bool {
 	return c.Reason == ""
 }
 
+// mergePath finds where a river that flowed into `other` at the pixel `from`
+// meets the course of `other` (the river, as in the network). Returns the
+// pixels of `other` we cross to get there (not including `from` if it is on
+// the course) & the index of the point of `joined` where we meet it, or -1.
+func mergePath(other *riverMap, joined *River, from *Pixel) ([]*Pixel, int) {
+	course := map[int]int{}
+	for j, p := range joined.Points {
+		i := p.Y*other.x + p.X
+		if _, ok := course[i]; !ok {
+			course[i] = j
+		}
+	}
+
+	// walk out across the water of the other river until we find it's course
+	start := from.Y()*other.x + from.X()
+	prev := map[int]*Pixel{start: nil}
+	queue := []*Pixel{from}
+	for n := 0; n < len(queue); n++ {
+		me := queue[n]
+		j, ok := course[me.Y()*other.x+me.X()]
+		if !ok {
+			for _, next := range other.Nearby(me.X(), me.Y(), 1, false) {
+				i := next.Y()*other.x + next.X()
+				if _, seen := prev[i]; seen || next.V == 0 {
+					continue
+				}
+				prev[i] = me
+				queue = append(queue, next)
+			}
+			continue
+		}
+
+		through := []*Pixel{}
+		for p := prev[me.Y()*other.x+me.X()]; p != nil; p = prev[p.Y()*other.x+p.X()] {
+			through = append([]*Pixel{p}, through...)
+		}
+		return through, j
+	}
+	return nil, -1
+}
+
 // drawRiver determines a path a river will follow.
 // There's actually a lot of steps we want to do here, including smoothing the river path & surrounds,
 // determining the direction of the river, ensuring it stops if / when it merges with another river etc.
 // Rather than go over the river path multiple times (as previously) we're going to attempt to do this
 // all at once & save on re-going over the path multiple times.
-func drawRiver(hmap, out, rain, sea, volc *MapImage, o *Pixel, cfg *riverSettings) (*MapImage, []*POI, []*Pixel) {
+// We return if the river ended by merging into another river.
+// Returns the pixel of another river we merged into, if any.
+func drawRiver(rng *rand.Rand, hmap, out, rain, sea, volc *MapImage, o *Pixel, cfg *riverSettings) (*riverMap, []*POI, []*Pixel, *Pixel) {
 	x, y := hmap.Dimensions()
 
 	pois := []*POI{&POI{X: o.X(), Y: o.Y(), Type: RiverOrigin}}
-	rvr := NewMapImage(x, y)
-	rvr.SetBackground(0)
+	rvr := newRiverMap(x, y)
+	rvr.wrapX = hmap.wrapX
 
 	// kick off by decrementing the hight of our river
-	riverbed := decrement(hmap.Value(o.X(), o.Y()), 5)
-	hmap.SetValue(o.X(), o.Y(), riverbed)
+	riverbed := decrementFloat(hmap.Float(o.X(), o.Y()), 5)
+	hmap.SetFloat(o.X(), o.Y(), riverbed)
+	// nb. the origin is part of the river too; the river network starts
+	// here, so the river maps must include it
+	rvr.SetValue(o.X(), o.Y(), 255)
+	out.SetValue(o.X(), o.Y(), 255)
 
 	// meander randomly until we reach the sea or another river
 	path := []*Pixel{o}
 
 	// direction is tricky. We want the river to change diretion, but not to twist wholly around,
 	// so we'll keep tabs on the currect direction & the starting direction.
-	startingdir := shapes.ToHeadingInt(rand.Intn(8))
+	startingdir := shapes.ToHeadingInt(rng.Intn(8))
 	if cfg.ForceNorthSouthSections {
 		// because we don't want to force such a sharp turn (East / West -> North / South)
-		if rand.Intn(2) == 1 {
+		if rng.Intn(2) == 1 {
 			startingdir = shapes.NORTH
 		} else {
 			startingdir = shapes.SOUTH
 		}
 	}
 	prevDir := startingdir.Right()
-	if rand.Intn(2) == 1 { // handling for extending rivers travelling diagonally
+	if rng.Intn(2) == 1 { // handling for extending rivers travelling diagonally
 		prevDir = startingdir.Left()
 	}
 	dir := startingdir
 	missedDecrements := 0
+	var merge *Pixel
 
 	startVolc := volc.Value(o.X(), o.Y())
 
@@ -239,7 +364,7 @@ func drawRiver(hmap, out, rain, sea, volc *MapImage, o *Pixel, cfg *riverSetting
 		// change direction left or right
 		if !possible[0].Ok() && !possible[1].Ok() && !possible[2].Ok() {
 			break // well, no where to go ..
-		} else if !possible[0].Ok() || rand.Float64() < cfg.TurnChance {
+		} else if !possible[0].Ok() || rng.Float64() < cfg.TurnChance {
 			if !possible[1].Ok() && possible[2].Ok() {
 				prevDir = dir
 				dir = possible[2].H
@@ -248,7 +373,7 @@ func drawRiver(hmap, out, rain, sea, volc *MapImage, o *Pixel, cfg *riverSetting
 				dir = possible[1].H
 			} else if possible[1].Ok() && possible[2].Ok() {
 				prevDir = dir
-				dir = possible[rand.Intn(2)+1].H
+				dir = possible[rng.Intn(2)+1].H
 			} else if possible[0].Ok() {
 				prevDir = dir
 				dir = possible[0].H
@@ -257,15 +382,15 @@ func drawRiver(hmap, out, rain, sea, volc *MapImage, o *Pixel, cfg *riverSetting
 
 		// figure out the next piece of the river
 		dx, dy := dir.RiseRun()
-		next := pix(this.X()+dx, this.Y()+dy, 255)
+		next := pix(hmap.wrap(this.X()+dx), this.Y()+dy, 255) // rivers can cross the seam if we wrap
 		path = append(path, next)
 
 		// decide new height of riverbed
-		h := min(hmap.Nearby(next.X(), next.Y(), 1, true))
+		h := minFloat(hmap, hmap.Nearby(next.X(), next.Y(), 1, true))
 		if cfg.ForceNorthSouthSections && (dir == shapes.NORTH || dir == shapes.SOUTH) {
 			// if we're forcing n/s and we're going n/s then we'll decrement as
 			// far as we need to
-			h = decrement(h, 1+uint8(missedDecrements))
+			h = decrementFloat(h, 1+float64(missedDecrements))
 			missedDecrements = 0
 		} else if cfg.ForceNorthSouthSections && !(dir == shapes.NORTH || dir == shapes.SOUTH) {
 			// if we're forcing n/s and we're not going n/s then we'll record that
@@ -273,7 +398,7 @@ func drawRiver(hmap, out, rain, sea, volc *MapImage, o *Pixel, cfg *riverSetting
 			missedDecrements++
 		} else {
 			// otherwise, each step of the river we'll decrement by 1
-			h = decrement(h, 1)
+			h = decrementFloat(h, 1)
 		}
 		if h < riverbed {
 			riverbed = h
@@ -285,13 +410,13 @@ func drawRiver(hmap, out, rain, sea, volc *MapImage, o *Pixel, cfg *riverSetting
 			px, py := prevDir.RiseRun()
 			rvr.SetValue(this.X()+px, this.Y()+py, 255)
 			out.SetValue(this.X()+px, this.Y()+py, 255)
-			hmap.SetValue(this.X()+px, this.Y()+py, riverbed)
+			hmap.SetFloat(this.X()+px, this.Y()+py, riverbed)
 		}
 
 		// record the river
 		rvr.SetValue(next.X(), next.Y(), 255)
 		out.SetValue(next.X(), next.Y(), 255)
-		hmap.SetValue(next.X(), next.Y(), riverbed)
+		hmap.SetFloat(next.X(), next.Y(), riverbed)
 
 		for _, near := range rvr.Nearby(next.X(), next.Y(), 10, true) {
 			// up rain / fresh water map since .. there's fresh water
@@ -305,17 +430,21 @@ func drawRiver(hmap, out, rain, sea, volc *MapImage, o *Pixel, cfg *riverSetting
 		)
 		nearme := rvr.Nearby(next.X(), next.Y(), 1, true)
 		touchesThisRiver := pixelsBetween(1, 255, nearme)
-		merge := false
 		if len(touchesRiver) > len(touchesThisRiver) {
-			merge = true
+			for _, p := range touchesRiver {
+				if rvr.Value(p.X(), p.Y()) == 0 {
+					merge = p
+					break
+				}
+			}
 		}
 
 		// if we are merging, flesh out the river around that so we merge gracefully
-		if merge {
+		if merge != nil {
 			for _, ground := range pixelsBetween(0, 0, nearme) {
 				rvr.SetValue(ground.X(), ground.Y(), 255)
 				out.SetValue(ground.X(), ground.Y(), 255)
-				hmap.SetValue(ground.X(), ground.Y(), riverbed)
+				hmap.SetFloat(ground.X(), ground.Y(), riverbed)
 			}
 			break
 		}
@@ -333,12 +462,13 @@ func drawRiver(hmap, out, rain, sea, volc *MapImage, o *Pixel, cfg *riverSetting
 	end := path[len(path)-1]
 	pois = append(pois, &POI{X: end.X(), Y: end.Y(), Type: RiverEnd})
 
-	return rvr, pois, path
+	return rvr, pois, path, merge
 }
 
 // riverOrigins figures out where rivers can start
-func riverOrigins(hmap *MapImage, cfg *riverSettings) []*Pixel {
+func riverOrigins(rng *rand.Rand, hmap *MapImage, cfg *riverSettings) []*Pixel {
 	return origins(
+		rng,
 		hmap,
 		cfg.OriginMinDist,
 		int(cfg.Number),
@@ -349,7 +479,7 @@ func riverOrigins(hmap *MapImage, cfg *riverSettings) []*Pixel {
 }
 
 // origins picks places between given heights on the map some dist apart
-func origins(hmap *MapImage, minDist float64, number int, omin, omax, minHeight uint8) []*Pixel {
+func origins(rng *rand.Rand, hmap *MapImage, minDist float64, number int, omin, omax, minHeight uint8) []*Pixel {
 	if minDist < 0 {
 		minDist = 0
 	}
@@ -376,7 +506,7 @@ func origins(hmap *MapImage, minDist float64, number int, omin, omax, minHeight
 		if len(candidates) == 0 { // no where looks good to start a river
 			break
 		}
-		shuffle(candidates)
+		shuffle(rng, candidates)
 
 		for _, origin := range candidates {
 			if len(origins) >= number {
@@ -384,7 +514,7 @@ func origins(hmap *MapImage, minDist float64, number int, omin, omax, minHeight
 			}
 			tooclose := false
 			for _, other := range origins {
-				if other.Point.DistPt(origin.Point) < minDist {
+				if hmap.dist(other.Point, origin.Point) < minDist {
 					tooclose = true
 					break
 				}
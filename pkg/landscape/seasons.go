@@ -0,0 +1,117 @@
+package landscape
+
+import (
+	"math"
+	"time"
+)
+
+// earthTilt is the axial tilt of the Earth in degrees, seasonal effects
+// are scaled relative to this
+const earthTilt = 23.44
+
+// determineContinentality returns a map of how far each pixel is from the
+// sea, where 0 is the sea or coast & 255 is at least `cfg.ContinentalRange`
+// pixels inland. Places far from the sea have hotter summers & colder winters.
+func determineContinentality(t *tracker, sea *MapImage, cfg *seasonSettings) (*MapImage, error) {
+	x, y := sea.Dimensions()
+	out := NewMapImage(x, y)
+	out.SetBackground(255)
+
+	limit := int(cfg.ContinentalRange)
+	dist, err := distanceFrom(t, sea, limit, func(v uint8) bool { return v == 255 })
+	if err != nil {
+		return nil, err
+	}
+
+	for i, d := range dist {
+		if d == 0 {
+			out.SetValue(i%x, i/x, 0)
+		} else if d > 0 {
+			out.SetValue(i%x, i/x, toUint8(255*float64(d)/float64(limit)))
+		}
+	}
+
+	return out, nil
+}
+
+// season returns how far into summer the given month is at row dy, where
+// 1 is midsummer & -1 is midwinter (northern summer peaks in July,
+// southern in January)
+func (l *Landscape) season(dy int, month time.Month) float64 {
+	_, y := l.Dimensions()
+	phase := math.Cos(2 * math.Pi * float64(month-time.July) / 12)
+	if dy > y/2 {
+		return -phase // southern hemisphere
+	}
+	return phase
+}
+
+// seasonSettings returns our season settings, or the defaults if we
+// don't have any (eg. older archives)
+func (l *Landscape) seasonSettings() *seasonSettings {
+	if l.config == nil || l.config.Seasons == nil {
+		return DefaultConfig().Seasons
+	}
+	return l.config.Seasons
+}
+
+// continentalityAt returns 0-1 how far from the sea x,y is
+func (l *Landscape) continentalityAt(x, y int) float64 {
+	if l.continentality == nil {
+		return 0.5
+	}
+	return l.continentality.Float(x, y) / 255
+}
+
+// TemperatureAt returns the average temperature at x,y in degrees
+// celsius during the given month.
+// The difference between summer & winter grows with latitude, distance from
+// the sea & the axial tilt of the world.
+func (l *Landscape) TemperatureAt(x, y int, month time.Month) float64 {
+	cfg := l.seasonSettings()
+	_, my := l.Dimensions()
+
+	lat := latitude(y, my) * math.Pi / 180
+	tilt := cfg.AxialTilt / earthTilt
+
+	// coasts see 30% of the range of places deep inland
+	amplitude := cfg.TempRange * tilt * math.Sin(lat) * (0.3 + 0.7*l.continentalityAt(x, y))
+
+	return l.TemperatureCelsius(x, y) + amplitude*l.season(y, month)
+}
+
+// RainfallAt returns the rainfall in mm at x,y during the given month.
+// Near the equator rain falls mostly in summer (the monsoon / wet season,
+// strongest inland), while in the mid latitudes coasts tend to be wetter in
+// winter & interiors in summer.
+// The rain of all 12 months adds up to AnnualRainfallMM.
+func (l *Landscape) RainfallAt(x, y int, month time.Month) float64 {
+	cfg := l.seasonSettings()
+	_, my := l.Dimensions()
+
+	lat := latitude(y, my)
+	tilt := math.Min(cfg.AxialTilt/earthTilt, 1)
+	cont := l.continentalityAt(x, y)
+
+	// how strongly rainfall follows the seasons (-1 wet winters, 1 wet summers)
+	strength := 0.0
+	if lat < 30 {
+		// the tropical rain belt follows the sun, lat 30 has no monsoon
+		strength = cfg.Monsoon * (0.5 + 0.5*cont) * (1 - lat/30)
+	} else if lat < 60 {
+		strength = 0.3 * (cont*2 - 1)
+	}
+	strength *= tilt
+
+	return l.AnnualRainfallMM(x, y) / 12 * (1 + strength*l.season(y, month))
+}
+
+// FrozenAt returns if water (sea, river or lake) at x,y freezes over during
+// the given month. Sea water is considered to freeze at -2c.
+func (l *Landscape) FrozenAt(x, y int, month time.Month) bool {
+	temp := l.TemperatureAt(x, y, month)
+	if l.sea.Value(x, y) == 255 {
+		return temp <= -2
+	}
+	return l.rivers.Value(x, y) == 255 && temp <= 0
+}
@@ -0,0 +1,80 @@
+package landscape
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetermineContinentality(t *testing.T) {
+	cfg := DefaultConfig().Seasons
+	cfg.ContinentalRange = 10
+
+	sea := NewMapImage(50, 5)
+	for dy := 0; dy < 5; dy++ {
+		sea.SetValue(0, dy, 255)
+	}
+
+	tr := newReporter(context.Background(), nil).stage(StageSeasons)
+	cont, err := determineContinentality(tr, sea, cfg)
+	assert.Nil(t, err)
+
+	assert.Equal(t, uint8(0), cont.Value(0, 2))
+	assert.Equal(t, uint8(128), cont.Value(5, 2))
+	assert.Equal(t, uint8(255), cont.Value(10, 2))
+	assert.Equal(t, uint8(255), cont.Value(40, 2))
+}
+
+func TestSeasons(t *testing.T) {
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	x, y := l.Dimensions()
+	for dx := 0; dx < x; dx += 13 {
+		for dy := 0; dy < y; dy += 13 {
+			// months average out to the annual values
+			temp := 0.0
+			rain := 0.0
+			for m := time.January; m <= time.December; m++ {
+				temp += l.TemperatureAt(dx, dy, m)
+				rain += l.RainfallAt(dx, dy, m)
+				assert.True(t, l.RainfallAt(dx, dy, m) >= 0)
+			}
+			assert.InDelta(t, l.TemperatureCelsius(dx, dy), temp/12, 1e-6)
+			assert.InDelta(t, l.AnnualRainfallMM(dx, dy), rain, 1e-6)
+		}
+	}
+
+	// summer is hotter than winter (away from the equator)
+	assert.True(t, l.TemperatureAt(x/2, 10, time.July) > l.TemperatureAt(x/2, 10, time.January))
+	assert.True(t, l.TemperatureAt(x/2, y-10, time.July) < l.TemperatureAt(x/2, y-10, time.January))
+
+	// without tilt there are no seasons
+	l.config.Seasons.AxialTilt = 0
+	assert.Equal(t, l.TemperatureAt(x/2, 10, time.July), l.TemperatureAt(x/2, 10, time.January))
+	assert.Equal(t, l.RainfallAt(x/2, y/2, time.July), l.RainfallAt(x/2, y/2, time.January))
+}
+
+func TestFrozenAt(t *testing.T) {
+	cfg := testConfig()
+	cfg.Temp.EquatorAverageTemp = 90 // -10c
+	cfg.Temp.PoleAverageTemp = 60
+
+	l, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+
+	x, y := l.Dimensions()
+	frozen := 0
+	for dx := 0; dx < x; dx++ {
+		for dy := 0; dy < y; dy++ {
+			if l.FrozenAt(dx, dy, time.January) {
+				frozen++
+				a := l.At(dx, dy)
+				assert.True(t, a.Sea || a.River)
+			}
+		}
+	}
+	assert.True(t, frozen > 0)
+}
@@ -0,0 +1,256 @@
+package landscape
+
+import (
+	"image/color"
+
+	"golang.org/x/image/colornames"
+)
+
+// Soil is the type of soil covering an area
+type Soil string
+
+var (
+	SoilNone       Soil = "none"        // water, lava or bare rock
+	SoilLoam       Soil = "loam"        // ordinary brown earth
+	SoilAlluvial   Soil = "alluvial"    // silt laid down by flooding rivers
+	SoilVolcanic   Soil = "volcanic"    // young soil weathered from volcanic rock
+	SoilPeat       Soil = "peat"        // waterlogged, partly rotted plants
+	SoilRocky      Soil = "rocky"       // thin soil on steep or high ground
+	SoilFrozen     Soil = "frozen"      // permafrost
+	SoilSandy      Soil = "sandy"       // dry with little organic matter
+	SoilLaterite   Soil = "laterite"    // hot & wet, nutrients washed out by rain
+	SoilPodzol     Soil = "podzol"      // cool & wet, nutrients washed out by rain
+	SoilBlackEarth Soil = "black-earth" // deep, rich grassland soil
+
+	// soils lists each soil, a soil's ID (it's value in the soil layer)
+	// is it's index here
+	soils = []Soil{
+		SoilNone,
+		SoilLoam,
+		SoilAlluvial,
+		SoilVolcanic,
+		SoilPeat,
+		SoilRocky,
+		SoilFrozen,
+		SoilSandy,
+		SoilLaterite,
+		SoilPodzol,
+		SoilBlackEarth,
+	}
+
+	// soilPalette holds the colour used to render each soil (by ID)
+	soilPalette = color.Palette{
+		colornames.Black,
+		colornames.Sienna,
+		colornames.Saddlebrown,
+		colornames.Dimgray,
+		colornames.Darkolivegreen,
+		colornames.Lightgray,
+		colornames.Lightcyan,
+		colornames.Khaki,
+		colornames.Firebrick,
+		colornames.Rosybrown,
+		colornames.Darkslategray,
+	}
+
+	// soilFertility is how fertile (0-1) each soil is, given enough water
+	soilFertility = map[Soil]float64{
+		SoilNone:       0,
+		SoilLoam:       0.7,
+		SoilAlluvial:   1,
+		SoilVolcanic:   0.95,
+		SoilPeat:       0.35,
+		SoilRocky:      0.15,
+		SoilFrozen:     0.05,
+		SoilSandy:      0.25,
+		SoilLaterite:   0.3,
+		SoilPodzol:     0.35,
+		SoilBlackEarth: 0.9,
+	}
+
+	// rockFertility scales fertility by the rock the soil weathered from,
+	// soil from hard metamorphic rock is thinner & poorer
+	rockFertility = map[Rock]float64{
+		RockNone:        1,
+		RockSedimentary: 1,
+		RockIgneous:     1,
+		RockMetamorphic: 0.85,
+	}
+)
+
+// soilID returns the ID of the given soil in the soil layer
+func soilID(s Soil) uint8 {
+	for i, v := range soils {
+		if v == s {
+			return uint8(i)
+		}
+	}
+	return 0
+}
+
+// toSoil returns the soil with the given ID
+func toSoil(id uint8) Soil {
+	if int(id) >= len(soils) {
+		return SoilNone
+	}
+	return soils[id]
+}
+
+// determineSoil returns maps of the soil type (by ID) & fertility (0-255) of
+// each pixel. Soil depends on the rock beneath (bedrock & volcanic land), water
+// (floodplains, swamps & how much rain washes nutrients away), climate & slope.
+func (l *Landscape) determineSoil(t *tracker, cfg *Config) (*MapImage, *MapImage, error) {
+	scfg := cfg.Soil
+	x, y := l.height.Dimensions()
+	soil := newPalettedMapImage(x, y, soilPalette)
+	fertility := NewMapImage(x, y)
+
+	floodplain, err := l.floodplains(t, scfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	radius := int(scfg.VolcanicRange)
+	volcanic, err := distanceFrom(t, l.volcanic, radius, func(v uint8) bool { return v > 0 })
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for dx := 0; dx < x; dx++ {
+		err := t.Progress(float64(dx) / float64(x))
+		if err != nil {
+			return nil, nil, err
+		}
+		for dy := 0; dy < y; dy++ {
+			i := dy*x + dx
+			if l.sea.Value(dx, dy) == 255 || l.rivers.Value(dx, dy) == 255 || l.volcanic.Value(dx, dy) == 255 {
+				continue // water & lava
+			}
+
+			slope := l.SlopeAt(dx, dy)
+			temp := l.TemperatureCelsius(dx, dy) - l.altitudeCooling(dx, dy)
+			rain := l.AnnualRainfallMM(dx, dy)
+			swamp := l.swamp.Value(dx, dy)
+
+			s := SoilLoam
+			switch {
+			case slope >= scfg.RockySlope || l.ElevationMeters(dx, dy) >= cfg.Vegetation.TreeLine+cfg.Vegetation.TreeLineFade:
+				s = SoilRocky
+			case temp < scfg.FrozenTemp:
+				s = SoilFrozen
+			case swamp == 255 || swamp == 120:
+				s = SoilPeat
+			case volcanic[i] >= 0:
+				s = SoilVolcanic
+			case floodplain[i]:
+				s = SoilAlluvial
+			case rain < scfg.DryRain:
+				s = SoilSandy
+			case rain >= scfg.LeachingRain && temp >= 20:
+				s = SoilLaterite
+			case rain >= scfg.LeachingRain*0.6 && temp < 8:
+				s = SoilPodzol
+			case rain < scfg.LeachingRain*0.5 && temp >= 5:
+				s = SoilBlackEarth
+			}
+			soil.SetValue(dx, dy, soilID(s))
+
+			// fertility is the soil's natural fertility, if there is water
+			// & the ground is neither too steep nor too cold
+			water := rain / cfg.Vegetation.Rainfall
+			if floodplain[i] {
+				water = 1
+			}
+			warmth := (temp - cfg.Vegetation.MinTemp) / (cfg.Vegetation.OptimalTemp - cfg.Vegetation.MinTemp)
+			flat := 1 - slope/scfg.RockySlope
+			f := soilFertility[s] * rockFertility[l.RockAt(dx, dy)] * (0.5 + 0.5*clamp01(water)) * clamp01(warmth) * clamp01(flat)
+			fertility.SetValue(dx, dy, toUint8(255*f))
+		}
+	}
+
+	return soil, fertility, nil
+}
+
+// floodplains returns which pixels are on the floodplain of a river, that is
+// land next to a river that is not much higher than the river's banks.
+// Larger rivers (by stream order) have wider floodplains.
+func (l *Landscape) floodplains(t *tracker, cfg *soilSettings) ([]bool, error) {
+	x, y := l.height.Dimensions()
+	out := make([]bool, x*y)
+	if l.network == nil || cfg.FloodplainRange == 0 {
+		return out, nil
+	}
+
+	// spread out from every river point, recording the nearest
+	type origin struct {
+		height float64
+		reach  int
+	}
+	nearest := make([]*origin, x*y)
+	dist := make([]int, x*y)
+	queue := []int{}
+	for _, r := range l.network.Rivers {
+		o := &origin{reach: int(cfg.FloodplainRange) * r.Order}
+		for _, p := range r.Points {
+			i := p.Y*x + p.X
+			if nearest[i] != nil {
+				continue
+			}
+			nearest[i] = &origin{height: p.Height, reach: o.reach}
+			queue = append(queue, i)
+		}
+	}
+
+	for n := 0; n < len(queue); n++ {
+		if n%1024 == 0 {
+			err := t.Err()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		i := queue[n]
+		o := nearest[i]
+		if dist[i] > 0 && l.height.Float(i%x, i/x)-o.height > cfg.FloodplainHeight {
+			continue // too far above the river
+		}
+		out[i] = true
+		if dist[i] >= o.reach {
+			continue
+		}
+
+		for _, d := range d8 {
+			j, ok := l.height.neighbour(i, d)
+			if !ok || nearest[j] != nil {
+				continue
+			}
+			nx, ny := j%x, j/x
+			nearest[j] = o
+			if isFreshWater(l.rivers.Value(i%x, i/x)) && !isFreshWater(l.rivers.Value(nx, ny)) {
+				// we've reached the bank, measure height from here
+				nearest[j] = &origin{height: l.height.Float(nx, ny), reach: o.reach}
+			}
+			dist[j] = dist[i] + 1
+			queue = append(queue, j)
+		}
+	}
+
+	return out, nil
+}
+
+// SoilAt returns the soil at x,y
+func (l *Landscape) SoilAt(x, y int) Soil {
+	if l.soil == nil {
+		return SoilNone
+	}
+	return toSoil(l.soil.Value(x, y))
+}
+
+// fertilityAt returns how fertile the soil at x,y is, 0 if we have no
+// fertility map (eg. an older archive)
+func (l *Landscape) fertilityAt(x, y int) uint8 {
+	if l.fertility == nil {
+		return 0
+	}
+	return l.fertility.Value(x, y)
+}
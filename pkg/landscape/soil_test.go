@@ -0,0 +1,143 @@
+package landscape
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+func TestDetermineSoil(t *testing.T) {
+	tr := newReporter(context.Background(), nil).stage(StageSoil)
+
+	l := flatLandscape(40, 10, 120, 115, 100)
+	l.volcanic.SetValue(39, 0, 100)
+	l.swamp.SetValue(20, 8, 255)
+
+	// a river along the top, flowing west
+	pts := []*RiverPoint{}
+	for dx := 0; dx < 20; dx++ {
+		l.rivers.SetValue(dx, 0, 255)
+		pts = append(pts, &RiverPoint{X: 19 - dx, Y: 0, Height: 120})
+	}
+	l.network = &RiverNetwork{}
+	l.network.add(l.height, pts)
+	l.network.finalise()
+
+	soil, fertility, err := l.determineSoil(tr, l.config)
+	assert.Nil(t, err)
+
+	assert.Equal(t, SoilNone, toSoil(soil.Value(5, 0)))
+	assert.Equal(t, uint8(0), fertility.Value(5, 0))
+	assert.Equal(t, SoilAlluvial, toSoil(soil.Value(5, 2)))
+	assert.Equal(t, SoilVolcanic, toSoil(soil.Value(36, 2)))
+	assert.Equal(t, SoilPeat, toSoil(soil.Value(20, 8)))
+	assert.Equal(t, SoilLoam, toSoil(soil.Value(5, 9)))
+
+	// floodplains are the most fertile
+	assert.True(t, fertility.Value(5, 2) > fertility.Value(5, 9))
+	assert.True(t, fertility.Value(5, 9) > fertility.Value(20, 8))
+
+	// rock & ice
+	for _, c := range []struct {
+		L      *Landscape
+		Expect Soil
+	}{
+		{flatLandscape(5, 5, 120, 60, 100), SoilFrozen},
+		{flatLandscape(5, 5, 120, 125, 10), SoilSandy},
+		{flatLandscape(5, 5, 120, 125, 250), SoilLaterite},
+		{flatLandscape(5, 5, 250, 120, 100), SoilRocky},
+	} {
+		soil, _, err := c.L.determineSoil(tr, c.L.config)
+		assert.Nil(t, err)
+		assert.Equal(t, c.Expect, toSoil(soil.Value(2, 2)), c.Expect)
+	}
+}
+
+func TestArableRegions(t *testing.T) {
+	l := flatLandscape(10, 10, 120, 115, 100)
+	l.fertility = NewMapImage(10, 10)
+
+	// an L shape & a lone pixel
+	for dy := 1; dy < 5; dy++ {
+		l.fertility.SetValue(1, dy, 200)
+	}
+	l.fertility.SetValue(2, 4, 200)
+	l.fertility.SetValue(3, 4, 100) // not fertile enough
+	l.fertility.SetValue(8, 8, 200)
+
+	regions := l.ArableRegions(150, 1)
+	assert.Equal(t, 2, len(regions))
+	assert.Equal(t, []*shapes.Point{
+		shapes.Pt(1, 1), shapes.Pt(2, 1), shapes.Pt(2, 4), shapes.Pt(3, 4), shapes.Pt(3, 5), shapes.Pt(1, 5),
+	}, regions[0].Points)
+	assert.Equal(t, []*shapes.Point{
+		shapes.Pt(8, 8), shapes.Pt(9, 8), shapes.Pt(9, 9), shapes.Pt(8, 9),
+	}, regions[1].Points)
+
+	assert.Equal(t, 1, len(l.ArableRegions(150, 2)))
+
+	// with a lower bar the L grows
+	regions = l.ArableRegions(50, 2)
+	assert.Equal(t, 1, len(regions))
+	assert.Contains(t, regions[0].Points, shapes.Pt(4, 5))
+}
+
+func TestArableRegionsWrapX(t *testing.T) {
+	l := flatLandscape(10, 10, 120, 115, 100)
+	l.fertility = NewMapImage(10, 10)
+	l.fertility.wrapX = true
+
+	// a square over the east-west edge & a band all the way around
+	for _, dx := range []int{8, 9, 0, 1} {
+		l.fertility.SetValue(dx, 1, 200)
+		l.fertility.SetValue(dx, 2, 200)
+	}
+	for dx := 0; dx < 10; dx++ {
+		l.fertility.SetValue(dx, 6, 200)
+	}
+
+	regions := l.ArableRegions(150, 1)
+	assert.Equal(t, 2, len(regions))
+	assert.Equal(t, []*shapes.Point{
+		shapes.Pt(8, 1), shapes.Pt(12, 1), shapes.Pt(12, 3), shapes.Pt(8, 3),
+	}, regions[0].Points)
+	assert.Equal(t, []*shapes.Point{
+		shapes.Pt(0, 6), shapes.Pt(10, 6), shapes.Pt(10, 7), shapes.Pt(0, 7),
+	}, regions[1].Points)
+}
+
+func TestSoil(t *testing.T) {
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	x, y := l.Dimensions()
+	for dx := 0; dx < x; dx++ {
+		for dy := 0; dy < y; dy++ {
+			a := l.At(dx, dy)
+			if a.Sea || a.River || a.Lava {
+				assert.Equal(t, SoilNone, a.Soil)
+				assert.Equal(t, uint8(0), a.Fertility)
+			}
+		}
+	}
+
+	// every arable region is land
+	for _, r := range l.ArableRegions(100, 10) {
+		assert.True(t, len(r.Points) >= 4)
+		for _, p := range r.Points {
+			assert.True(t, p.X >= 0 && p.Y >= 0 && p.X <= float64(x) && p.Y <= float64(y))
+		}
+	}
+}
+
+func TestAtWithoutSoil(t *testing.T) {
+	l := flatLandscape(5, 5, 120, 120, 100)
+	l.biomes = NewMapImage(5, 5)
+
+	a := l.At(2, 2)
+	assert.NotNil(t, a)
+	assert.Equal(t, SoilNone, a.Soil)
+	assert.Equal(t, uint8(0), a.Fertility)
+}
@@ -0,0 +1,230 @@
+package landscape
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+// HeightmapMode decides how we generate the initial heightmap
+type HeightmapMode string
+
+const (
+	// HeightmapPerlin blends two layers of perlin noise, one for the general
+	// lie of the land & one for mountains
+	HeightmapPerlin HeightmapMode = "perlin"
+
+	// HeightmapTectonic splits the world into moving tectonic plates, raising
+	// mountain ranges & volcanic arcs where they collide & forming rifts and
+	// trenches where they pull apart
+	HeightmapTectonic HeightmapMode = "tectonic"
+)
+
+// plate is a single tectonic plate
+type plate struct {
+	// direction & speed (0-1) the plate is moving
+	vx, vy float64
+
+	// oceanic plates sit below sea level & sink beneath others when they collide
+	oceanic bool
+
+	// height of the plate away from any boundary
+	base float64
+}
+
+// newPlates returns `n` plates
+func newPlates(rng *rand.Rand, n int, sealevel uint8, ls *landSettings) []*plate {
+	plates := make([]*plate, n)
+	for i := range plates {
+		heading := rng.Float64() * 2 * math.Pi
+		speed := 0.2 + 0.8*rng.Float64()
+
+		p := &plate{
+			vx:      math.Cos(heading) * speed,
+			vy:      math.Sin(heading) * speed,
+			oceanic: rng.Float64() < ls.OceanicPlates,
+		}
+		if p.oceanic {
+			p.base = float64(sealevel) - 50
+		} else {
+			p.base = float64(sealevel) + 15
+		}
+		plates[i] = p
+	}
+	return plates
+}
+
+// convergence returns -1 to 1 how fast plates a & b (with the given sites) are
+// moving towards (positive) or away from (negative) each other
+func convergence(a, b *plate, sa, sb *shapes.Point) float64 {
+	nx, ny := sb.X-sa.X, sb.Y-sa.Y
+	length := math.Hypot(nx, ny)
+	if length == 0 {
+		return 0
+	}
+	return ((a.vx-b.vx)*nx + (a.vy-b.vy)*ny) / length / 2
+}
+
+// distToSegment returns the distance from px,py to the line segment a-b
+func distToSegment(px, py float64, a, b *shapes.Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lsq := dx*dx + dy*dy
+	if lsq == 0 {
+		return math.Hypot(px-a.X, py-a.Y)
+	}
+	t := math.Max(0, math.Min(1, ((px-a.X)*dx+(py-a.Y)*dy)/lsq))
+	return math.Hypot(px-(a.X+t*dx), py-(a.Y+t*dy))
+}
+
+// ridge returns 0-1 the profile of a feature running alongside a plate
+// boundary at t (0-1, where 1 is BoundaryWidth) from it. The feature peaks
+// at t = peak & falls away to 0 at t = 1 (and at the boundary, if peak > 0).
+func ridge(t, peak float64) float64 {
+	if t >= 1 || t < 0 {
+		return 0
+	}
+	u := (t - peak) / (1 - peak)
+	if t < peak {
+		u = (peak - t) / peak
+	}
+	return (1 - u*u) * (1 - u*u)
+}
+
+// boundaryEffect returns the change in height & the tectonic activity (0-1)
+// at t (0-1, where 1 is BoundaryWidth) from the boundary between plate `me`
+// and plate `other` on my side of the boundary, given the convergence c
+// between them.
+func boundaryEffect(me, other *plate, meID, otherID int, c, t float64, ls *landSettings) (float64, float64) {
+	switch {
+	case c > 0 && me.oceanic && other.oceanic:
+		// the plate with the lower ID sinks beneath the other, forming a
+		// trench & a chain of volcanic islands
+		if meID < otherID {
+			return -ls.RiftDepth * c * ridge(t, 0.15), 0
+		}
+		return ls.UpliftHeight * 0.8 * c * ridge(t, 0.35), c * ridge(t, 0.35)
+	case c > 0 && me.oceanic:
+		// subducting beneath a continent, forming a trench
+		return -ls.RiftDepth * c * ridge(t, 0.15), 0
+	case c > 0 && other.oceanic:
+		// a continent over a subducting plate, forming a volcanic arc
+		return ls.UpliftHeight * c * ridge(t, 0.35), c * ridge(t, 0.35)
+	case c > 0:
+		// continents collide, crumpling up into a mountain belt
+		return ls.UpliftHeight * c * ridge(t, 0), 0.25 * c * ridge(t, 0)
+	case me.oceanic && other.oceanic:
+		// plates pull apart under the sea, welling up into a mid ocean ridge
+		return ls.RiftDepth * 0.5 * -c * ridge(t, 0), 0.5 * -c * ridge(t, 0)
+	default:
+		// a continent tears apart, forming a rift valley
+		return -ls.RiftDepth * -c * ridge(t, 0), 0.5 * -c * ridge(t, 0)
+	}
+}
+
+// determineTectonics returns a heightmap formed by plate tectonics & a map of
+// how tectonically active (0-255) each pixel is, where the most active areas
+// are volcanic arcs above subducting plates.
+// The map is split into voronoi cells (plates), each either oceanic or
+// continental & moving in some direction. Where plates meet their relative
+// motion decides what forms along the boundary. Plate boundaries are warped
+// with noise so they don't run in straight lines & the resulting heightmap
+// is blended with noise (HeightVariance & MountainVariance) to add detail.
+// If wrapX is set plates carry on over the east & west edges of the map.
+func determineTectonics(t *tracker, rng *rand.Rand, x, y int, sealevel uint8, ls *landSettings, wrapX bool) (*MapImage, *MapImage, error) {
+	graph, siteOf, err := voronoiCells(rng, x, y, int(ls.Plates), wrapX)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w for tectonic plates", err)
+	}
+	n := len(graph.Cells)
+	if wrapX {
+		n = int(ls.Plates)
+	}
+	plates := newPlates(rng, n, sealevel, ls)
+
+	// how fast plates either side of each edge are converging, relative
+	// to the fastest moving boundary
+	converging := make([]float64, len(graph.Edges))
+	fastest := 0.0
+	for i, e := range graph.Edges {
+		if e.LeftCell == nil || e.RightCell == nil {
+			continue // edge of the map
+		}
+		converging[i] = convergence(plates[siteOf[e.LeftCell.ID]], plates[siteOf[e.RightCell.ID]], e.LeftCell.Site, e.RightCell.Site)
+		fastest = math.Max(fastest, math.Abs(converging[i]))
+	}
+	if fastest > 0 {
+		for i := range converging {
+			converging[i] /= fastest
+		}
+	}
+
+	warpx := noise16(rng, x, y, ls.HeightVariance, wrapX)
+	warpy := noise16(rng, x, y, ls.HeightVariance, wrapX)
+
+	width := math.Max(float64(ls.BoundaryWidth), 1)
+	warp := func(im *MapImage, dx, dy int) float64 {
+		return (im.Float(dx, dy)/255 - 0.5) * 2 * float64(ls.BoundaryWarp)
+	}
+
+	tect := NewMapImage16(x, y)
+	activity := NewMapImage(x, y)
+	activity.wrapX = wrapX
+
+	for dx := 0; dx < x; dx++ {
+		err := t.Progress(float64(dx) / float64(x))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for dy := 0; dy < y; dy++ {
+			px := float64(dx) + warp(warpx, dx, dy)
+			py := float64(dy) + warp(warpy, dx, dy)
+
+			cell := nearestCell(graph, px, py)
+			id := siteOf[cell]
+			me := plates[id]
+			h := me.base
+			act := 0.0
+
+			for _, half := range graph.Cells[cell].Edges {
+				e := half.Edge
+				if e.LeftCell == nil || e.RightCell == nil {
+					continue
+				}
+				otherID := siteOf[e.LeftCell.ID]
+				if e.LeftCell.ID == cell {
+					otherID = siteOf[e.RightCell.ID]
+				}
+				if otherID == id {
+					continue // a plate meeting itself over the edge of the map
+				}
+				other := plates[otherID]
+
+				dt := distToSegment(px, py, e.PointA, e.PointB) / width
+				if dt >= 1 {
+					continue
+				}
+
+				// blend towards the other plate, so we meet it half way
+				h += (other.base - me.base) / 2 * ridge(dt, 0)
+
+				dh, da := boundaryEffect(me, other, id, otherID, converging[e.ID], dt, ls)
+				h += dh
+				act = math.Max(act, da)
+			}
+
+			tect.SetFloat(dx, dy, h)
+			activity.SetValue(dx, dy, toUint8(act*255))
+		}
+	}
+
+	height := combine(
+		weight(tect, 1-ls.NoiseWeight),
+		weight(noise16(rng, x, y, ls.HeightVariance, wrapX), ls.NoiseWeight/2),
+		weight(noise16(rng, x, y, ls.MountainVariance, wrapX), ls.NoiseWeight/2),
+	)
+	height.wrapX = wrapX
+	return height, activity, nil
+}
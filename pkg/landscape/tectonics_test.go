@@ -0,0 +1,164 @@
+package landscape
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+func TestRidge(t *testing.T) {
+	assert.Equal(t, 1.0, ridge(0, 0))
+	assert.Equal(t, 0.0, ridge(1, 0))
+	assert.Equal(t, 0.0, ridge(0, 0.35))
+	assert.Equal(t, 1.0, ridge(0.35, 0.35))
+	assert.Equal(t, 0.0, ridge(1.5, 0.35))
+	assert.True(t, ridge(0.2, 0) > ridge(0.5, 0))
+}
+
+func TestConvergence(t *testing.T) {
+	west := shapes.Pt(0, 0)
+	east := shapes.Pt(10, 0)
+
+	eastwards := &plate{vx: 1}
+	westwards := &plate{vx: -1}
+	still := &plate{}
+
+	assert.Equal(t, 1.0, convergence(eastwards, westwards, west, east))
+	assert.Equal(t, 1.0, convergence(westwards, eastwards, east, west))
+	assert.Equal(t, -1.0, convergence(westwards, eastwards, west, east))
+	assert.Equal(t, 0.5, convergence(eastwards, still, west, east))
+
+	// sliding past each other
+	north := &plate{vy: -1}
+	south := &plate{vy: 1}
+	assert.Equal(t, 0.0, convergence(north, south, west, east))
+}
+
+func TestBoundaryEffect(t *testing.T) {
+	ls := DefaultConfig().Land
+	ocean := &plate{oceanic: true}
+	land := &plate{}
+
+	// continents collide into mountains
+	dh, _ := boundaryEffect(land, land, 0, 1, 1, 0, ls)
+	assert.Equal(t, ls.UpliftHeight, dh)
+
+	// an ocean sinks beneath a continent, forming a trench on one side
+	// & a volcanic arc on the other
+	dh, act := boundaryEffect(ocean, land, 0, 1, 1, 0.15, ls)
+	assert.Equal(t, -ls.RiftDepth, dh)
+	assert.Equal(t, 0.0, act)
+
+	dh, act = boundaryEffect(land, ocean, 1, 0, 1, 0.35, ls)
+	assert.Equal(t, ls.UpliftHeight, dh)
+	assert.Equal(t, 1.0, act)
+
+	// continents pulling apart form a rift
+	dh, _ = boundaryEffect(land, land, 0, 1, -1, 0, ls)
+	assert.Equal(t, -ls.RiftDepth, dh)
+
+	// oceans pulling apart form a ridge
+	dh, _ = boundaryEffect(ocean, ocean, 0, 1, -1, 0, ls)
+	assert.True(t, dh > 0)
+
+	// nothing happens far from the boundary
+	dh, act = boundaryEffect(land, ocean, 1, 0, 1, 1, ls)
+	assert.Equal(t, 0.0, dh)
+	assert.Equal(t, 0.0, act)
+}
+
+func TestDetermineTectonics(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Oceanic float64
+		Land    bool
+	}{
+		{"continents", 0, true},
+		{"oceans", 1, false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ls := DefaultConfig().Land
+			ls.Plates = 6
+			ls.BoundaryWidth = 10
+			ls.BoundaryWarp = 5
+			ls.OceanicPlates = tt.Oceanic
+
+			tr := newReporter(context.Background(), nil).stage(StageHeightmap)
+			height, tect, err := determineTectonics(tr, rand.New(rand.NewSource(4)), 100, 80, 115, ls, false)
+			assert.Nil(t, err)
+
+			x, y := height.Dimensions()
+			assert.Equal(t, 100, x)
+			assert.Equal(t, 80, y)
+
+			land := 0
+			active := 0
+			for dx := 0; dx < x; dx++ {
+				for dy := 0; dy < y; dy++ {
+					if height.Value(dx, dy) >= 115 {
+						land++
+					}
+					if tect.Value(dx, dy) > 0 {
+						active++
+					}
+				}
+			}
+			assert.Equal(t, tt.Land, land > x*y/2)
+			assert.True(t, active > 0)
+			assert.True(t, active < x*y)
+		})
+	}
+}
+
+func TestGeothermalOriginsPreferBoundaries(t *testing.T) {
+	cfg := DefaultConfig().Volcanic
+	cfg.OriginMinDist = 5
+
+	hmap := NewMapImage(50, 50)
+	hmap.SetBackground(200)
+	tect := NewMapImage(50, 50)
+	for dy := 0; dy < 50; dy++ {
+		tect.SetValue(20, dy, 255)
+	}
+
+	found := geothermalOrigins(rand.New(rand.NewSource(1)), hmap, tect, cfg)
+	assert.Equal(t, int(cfg.Number), len(found))
+	for _, p := range found {
+		assert.Equal(t, 20, p.X())
+	}
+}
+
+func TestTectonicLandscape(t *testing.T) {
+	cfg := testConfig()
+	cfg.Land.Mode = HeightmapTectonic
+	cfg.Land.BoundaryWidth = 15
+	cfg.Land.BoundaryWarp = 10
+
+	l, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+
+	volcanoes := 0
+	for _, p := range l.PointsOfInterest() {
+		if p.Type == Volcano {
+			volcanoes++
+			assert.True(t, l.tectonics.Value(p.X, p.Y) > 0)
+		}
+	}
+	assert.True(t, volcanoes > 0)
+}
+
+func TestTectonicLandscapeNoPlates(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Width, cfg.Height = 60, 60
+	cfg.Land.Mode = HeightmapTectonic
+	cfg.Land.Plates = 0
+
+	_, err := PerlinLandscape(cfg)
+	assert.NotNil(t, err)
+}
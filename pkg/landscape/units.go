@@ -0,0 +1,113 @@
+package landscape
+
+import (
+	"math"
+)
+
+const (
+	// temperatureZero is the temperature value that is 0c,
+	// each unit of temperature is 1c
+	temperatureZero = 100
+)
+
+// Scale converts map values into real world units
+type Scale struct {
+	// width & height of a single pixel in metres
+	MetresPerPixel float64
+
+	// metres per unit of height (0-255)
+	MetresPerHeightUnit float64
+
+	// elevation (in metres) given to sea level, elevations are otherwise
+	// relative to the configured sea level (Config.Sea.SeaLevel)
+	SeaLevelOffset float64
+
+	// mm of annual rainfall per unit of rainfall (0-255)
+	RainfallMMPerUnit float64
+}
+
+// defaultScale roughly follows our assumptions in determineTemp;
+// 255 height is Mt Everest (~8800m) & 0 is the Java Trench (~7200m)
+func defaultScale() *Scale {
+	return &Scale{
+		MetresPerPixel:      1000,
+		MetresPerHeightUnit: 63,
+		SeaLevelOffset:      0,
+		RainfallMMPerUnit:   10,
+	}
+}
+
+// Distance returns the given number of pixels in metres
+func (s *Scale) Distance(pixels float64) float64 {
+	return pixels * s.MetresPerPixel
+}
+
+// Scale returns the scale used to convert map values to real world units
+func (l *Landscape) Scale() *Scale {
+	if l.config == nil || l.config.Scale == nil {
+		return defaultScale()
+	}
+	return l.config.Scale
+}
+
+// seaLevel returns the height of sea level
+func (l *Landscape) seaLevel() float64 {
+	if l.config == nil || l.config.Sea == nil {
+		return float64(DefaultConfig().Sea.SeaLevel)
+	}
+	return float64(l.config.Sea.SeaLevel)
+}
+
+// ElevationMeters returns the height above (or below, if negative) sea
+// level in metres at x,y
+func (l *Landscape) ElevationMeters(x, y int) float64 {
+	s := l.Scale()
+	return (l.height.Float(x, y)-l.seaLevel())*s.MetresPerHeightUnit + s.SeaLevelOffset
+}
+
+// DepthMeters returns the depth of the sea in metres at x,y,
+// 0 is returned if x,y is not sea
+func (l *Landscape) DepthMeters(x, y int) float64 {
+	if l.sea.Value(x, y) != 255 {
+		return 0
+	}
+	depth := -l.ElevationMeters(x, y)
+	if depth < 0 {
+		return 0
+	}
+	return depth
+}
+
+// TemperatureCelsius returns the average temperature in degrees celsius at x,y
+func (l *Landscape) TemperatureCelsius(x, y int) float64 {
+	return l.temperature.Float(x, y) - temperatureZero
+}
+
+// AnnualRainfallMM returns the average annual rainfall in mm at x,y
+func (l *Landscape) AnnualRainfallMM(x, y int) float64 {
+	return l.rainfall.Float(x, y) * l.Scale().RainfallMMPerUnit
+}
+
+// SlopeAt returns the steepness of the land at x,y as rise over run, where
+// 1 is a 45 degree slope
+func (l *Landscape) SlopeAt(x, y int) float64 {
+	maxx, maxy := l.Dimensions()
+	// nb. at the map edge we use the pixel itself in place of it's neighbour,
+	// unless the map wraps east-west & the neighbour is over the edge
+	x0, x1 := maxInt(x-1, 0), minInt(x+1, maxx-1)
+	if l.height.WrapsX() {
+		x0, x1 = x-1, x+1
+	}
+	y0, y1 := maxInt(y-1, 0), minInt(y+1, maxy-1)
+
+	s := l.Scale()
+	dzdx := 0.0
+	if x1 > x0 {
+		dzdx = (l.height.Float(x1, y) - l.height.Float(x0, y)) * s.MetresPerHeightUnit / s.Distance(float64(x1-x0))
+	}
+	dzdy := 0.0
+	if y1 > y0 {
+		dzdy = (l.height.Float(x, y1) - l.height.Float(x, y0)) * s.MetresPerHeightUnit / s.Distance(float64(y1-y0))
+	}
+	return math.Hypot(dzdx, dzdy)
+}
@@ -0,0 +1,73 @@
+package landscape
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnits(t *testing.T) {
+	cfg := testConfig()
+	cfg.Scale = &Scale{
+		MetresPerPixel:      50,
+		MetresPerHeightUnit: 10,
+		SeaLevelOffset:      5,
+		RainfallMMPerUnit:   2,
+	}
+
+	l, err := PerlinLandscape(cfg)
+	assert.Nil(t, err)
+
+	x, y := l.Dimensions()
+	seaLevel := float64(cfg.Sea.SeaLevel)
+	for dx := 0; dx < x; dx += 7 {
+		for dy := 0; dy < y; dy += 7 {
+			a := l.At(dx, dy)
+
+			assert.InDelta(t, (a.Height-seaLevel)*10+5, l.ElevationMeters(dx, dy), 1e-9)
+			assert.InDelta(t, a.Temperature-100, l.TemperatureCelsius(dx, dy), 1e-9)
+			assert.InDelta(t, a.Rainfall*2, l.AnnualRainfallMM(dx, dy), 1e-9)
+
+			depth := l.DepthMeters(dx, dy)
+			assert.True(t, depth >= 0)
+			if !a.Sea {
+				assert.Equal(t, 0.0, depth)
+			}
+
+			// areas carry the same
+			assert.Equal(t, l.ElevationMeters(dx, dy), a.ElevationMeters)
+			assert.Equal(t, depth, a.DepthMeters)
+			assert.Equal(t, l.TemperatureCelsius(dx, dy), a.TemperatureCelsius)
+			assert.Equal(t, l.AnnualRainfallMM(dx, dy), a.AnnualRainfallMM)
+		}
+	}
+	assert.Equal(t, 500.0, l.Scale().Distance(10))
+
+	// the scale is saved with the map
+	buff := new(bytes.Buffer)
+	assert.Nil(t, l.Save(buff))
+	result, err := Load(buff)
+	assert.Nil(t, err)
+	assert.Equal(t, cfg.Scale, result.Scale())
+}
+
+func TestUnitsDefaultScale(t *testing.T) {
+	l := &Landscape{}
+	assert.Equal(t, defaultScale(), l.Scale())
+}
+
+func TestSlopeAt(t *testing.T) {
+	l := flatLandscape(5, 5, 120, 120, 100)
+	assert.Equal(t, 0.0, l.SlopeAt(2, 2))
+
+	// rising 1 height unit (63m) every 63m
+	l.config.Scale = &Scale{MetresPerPixel: 63, MetresPerHeightUnit: 63}
+	for dx := 0; dx < 5; dx++ {
+		for dy := 0; dy < 5; dy++ {
+			l.height.SetFloat(dx, dy, float64(100+dx))
+		}
+	}
+	assert.InDelta(t, 1, l.SlopeAt(2, 2), 1e-3)
+	assert.InDelta(t, 1, l.SlopeAt(0, 0), 1e-3)
+}
@@ -6,8 +6,43 @@ import (
 	"image/png"
 	"io/ioutil"
 	"math"
+	"math/rand"
+
+	perlin "github.com/voidshard/cartographer/pkg/perlin"
 )
 
+// noise returns a new perlin noise map seeded from the given rng, if wrapX
+// is set the noise (& map) wraps east-west
+func noise(rng *rand.Rand, x, y int, variance float64, wrapX bool) *MapImage {
+	if wrapX {
+		m := newMapImageFrom(perlin.PerlinSeedWrapX(x, y, variance, rng.Int63()), nil)
+		m.wrapX = true
+		return m
+	}
+	return newMapImageFrom(perlin.PerlinSeed(x, y, variance, rng.Int63()), nil)
+}
+
+// noise16 returns a new 16 bit perlin noise map seeded from the given rng,
+// if wrapX is set the noise (& map) wraps east-west
+func noise16(rng *rand.Rand, x, y int, variance float64, wrapX bool) *MapImage {
+	if wrapX {
+		m := newMapImageFrom(perlin.PerlinSeed16WrapX(x, y, variance, rng.Int63()), nil)
+		m.wrapX = true
+		return m
+	}
+	return newMapImageFrom(perlin.PerlinSeed16(x, y, variance, rng.Int63()), nil)
+}
+
+// wrapCoord returns v wrapped into the range 0 to size-1
+func wrapCoord(v, size int) int {
+	return ((v % size) + size) % size
+}
+
+// decrementFloat subtracts i from v with a min value of 0
+func decrementFloat(v, i float64) float64 {
+	return math.Max(v-i, 0)
+}
+
 // decrement uint8 with min value of 0
 func decrement(v, i uint8) uint8 {
 	if v >= i {
@@ -45,3 +80,87 @@ func toUint8(f float64) uint8 {
 	}
 	return uint8(f)
 }
+
+// minInt returns the smaller of a & b
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the larger of a & b
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// clamp01 forces v to be within 0-1
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	} else if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// isFreshWater returns if the given value of the river layer is a river or lake
+func isFreshWater(v uint8) bool {
+	return v == 255
+}
+
+// distanceFrom returns how far (in pixels) each pixel is from the nearest
+// pixel whose value passes `is`, up to `limit` pixels. Pixels further away
+// are -1.
+func distanceFrom(t *tracker, im *MapImage, limit int, is func(uint8) bool) ([]int, error) {
+	dist, _, err := nearestFrom(t, im, limit, is)
+	return dist, err
+}
+
+// nearestFrom is distanceFrom but also returns the index of the nearest
+// pixel whose value passes `is` for each pixel (-1 if further than `limit`)
+func nearestFrom(t *tracker, im *MapImage, limit int, is func(uint8) bool) ([]int, []int, error) {
+	x, y := im.Dimensions()
+
+	dist := make([]int, x*y)
+	nearest := make([]int, x*y)
+	queue := []int{}
+	for i := range dist {
+		dist[i] = -1
+		nearest[i] = -1
+		if is(im.Value(i%x, i/x)) {
+			dist[i] = 0
+			nearest[i] = i
+			queue = append(queue, i)
+		}
+	}
+
+	for n := 0; n < len(queue); n++ {
+		if n%1024 == 0 {
+			err := t.Err()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		i := queue[n]
+		if dist[i] >= limit {
+			continue
+		}
+
+		for _, d := range d8 {
+			j, ok := im.neighbour(i, d)
+			if !ok || dist[j] >= 0 {
+				continue
+			}
+			dist[j] = dist[i] + 1
+			nearest[j] = nearest[i]
+			queue = append(queue, j)
+		}
+	}
+
+	return dist, nearest, nil
+}
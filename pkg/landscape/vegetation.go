@@ -0,0 +1,69 @@
+package landscape
+
+// determineVegetation returns a map of how densely vegetated (0-255) each
+// pixel is, where 255 is dense forest & 0 is bare ground or water.
+// Plants need water (rain, or rivers & lakes nearby), warmth & ground that
+// is neither too steep nor too high.
+func (l *Landscape) determineVegetation(t *tracker, cfg *Config) (*MapImage, error) {
+	vcfg := cfg.Vegetation
+	x, y := l.height.Dimensions()
+	out := NewMapImage(x, y)
+
+	radius := int(cfg.Biome.FreshWaterRadius)
+	fresh, err := distanceFrom(t, l.rivers, radius, isFreshWater)
+	if err != nil {
+		return nil, err
+	}
+
+	for dx := 0; dx < x; dx++ {
+		err := t.Progress(float64(dx) / float64(x))
+		if err != nil {
+			return nil, err
+		}
+		for dy := 0; dy < y; dy++ {
+			if l.sea.Value(dx, dy) == 255 || l.rivers.Value(dx, dy) == 255 || l.volcanic.Value(dx, dy) == 255 {
+				continue // water & lava
+			}
+
+			// water from rain, topped up by nearby rivers & lakes
+			water := l.AnnualRainfallMM(dx, dy) / vcfg.Rainfall
+			dist := fresh[dy*x+dx]
+			if dist >= 0 && radius > 0 {
+				water += vcfg.FreshWater * (1 - float64(dist)/float64(radius+1))
+			}
+			swamp := l.swamp.Value(dx, dy)
+			if swamp == 255 || swamp == 120 {
+				water = 1
+			}
+
+			// warmth, after cooling with altitude
+			temp := l.TemperatureCelsius(dx, dy) - l.altitudeCooling(dx, dy)
+			warmth := (temp - vcfg.MinTemp) / (vcfg.OptimalTemp - vcfg.MinTemp)
+
+			// steep ground holds little soil
+			slope := 1 - l.SlopeAt(dx, dy)/vcfg.MaxSlope
+
+			// above the tree line only hardy plants grow
+			altitude := 1.0
+			above := l.ElevationMeters(dx, dy) - vcfg.TreeLine
+			if above > 0 && vcfg.TreeLineFade > 0 {
+				altitude = 1 - above/vcfg.TreeLineFade
+			} else if above > 0 {
+				altitude = 0
+			}
+
+			out.SetValue(dx, dy, toUint8(255*clamp01(water)*clamp01(warmth)*clamp01(slope)*clamp01(altitude)))
+		}
+	}
+
+	return out, nil
+}
+
+// vegetationAt returns how densely vegetated x,y is, 0 if we have no
+// vegetation map (eg. an older archive)
+func (l *Landscape) vegetationAt(x, y int) uint8 {
+	if l.vegetation == nil {
+		return 0
+	}
+	return l.vegetation.Value(x, y)
+}
@@ -0,0 +1,94 @@
+package landscape
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flatLandscape returns a landscape of flat land at the given height with
+// an even temperature & rainfall
+func flatLandscape(x, y int, height, temp, rain float64) *Landscape {
+	l := &Landscape{
+		config:      DefaultConfig(),
+		height:      NewMapImage16(x, y),
+		sea:         NewMapImage(x, y),
+		rivers:      NewMapImage(x, y),
+		temperature: NewMapImage16(x, y),
+		rainfall:    NewMapImage16(x, y),
+		swamp:       NewMapImage(x, y),
+		volcanic:    NewMapImage(x, y),
+	}
+	for dx := 0; dx < x; dx++ {
+		for dy := 0; dy < y; dy++ {
+			l.height.SetFloat(dx, dy, height)
+			l.temperature.SetFloat(dx, dy, temp)
+			l.rainfall.SetFloat(dx, dy, rain)
+		}
+	}
+	return l
+}
+
+func TestDetermineVegetation(t *testing.T) {
+	tr := newReporter(context.Background(), nil).stage(StageVegetation)
+
+	l := flatLandscape(30, 10, 120, 120, 100)
+	l.rivers.SetValue(0, 5, 255)
+	l.sea.SetValue(29, 5, 255)
+	l.volcanic.SetValue(29, 0, 255)
+	l.swamp.SetValue(20, 0, 255)
+	for dy := 0; dy < 10; dy++ {
+		l.height.SetFloat(15, dy, 200) // a cliff
+	}
+
+	veg, err := l.determineVegetation(tr, l.config)
+	assert.Nil(t, err)
+
+	// water & lava
+	assert.Equal(t, uint8(0), veg.Value(0, 5))
+	assert.Equal(t, uint8(0), veg.Value(29, 5))
+	assert.Equal(t, uint8(0), veg.Value(29, 0))
+
+	// plants grow better by rivers & in swamps
+	assert.True(t, veg.Value(1, 5) > veg.Value(12, 5))
+	assert.True(t, veg.Value(20, 0) > veg.Value(22, 0))
+
+	// but not on cliffs
+	assert.True(t, veg.Value(15, 5) < veg.Value(12, 5))
+
+	// cold, dry & high places are bare
+	for _, l := range []*Landscape{
+		flatLandscape(5, 5, 120, 70, 100),
+		flatLandscape(5, 5, 120, 120, 0),
+		flatLandscape(5, 5, 250, 120, 100),
+	} {
+		veg, err := l.determineVegetation(tr, l.config)
+		assert.Nil(t, err)
+		assert.Equal(t, uint8(0), veg.Value(2, 2))
+	}
+}
+
+func TestVegetation(t *testing.T) {
+	l, err := PerlinLandscape(testConfig())
+	assert.Nil(t, err)
+
+	x, y := l.Dimensions()
+	for dx := 0; dx < x; dx++ {
+		for dy := 0; dy < y; dy++ {
+			a := l.At(dx, dy)
+			if a.Sea || a.River || a.Lava {
+				assert.Equal(t, uint8(0), a.Vegetation)
+			}
+		}
+	}
+}
+
+func TestAtWithoutVegetation(t *testing.T) {
+	l := flatLandscape(5, 5, 120, 120, 100)
+	l.biomes = NewMapImage(5, 5)
+
+	a := l.At(2, 2)
+	assert.NotNil(t, a)
+	assert.Equal(t, uint8(0), a.Vegetation)
+}
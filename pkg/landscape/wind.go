@@ -0,0 +1,157 @@
+package landscape
+
+import (
+	"math"
+	"sort"
+
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+// WindMode decides how prevailing winds blow when determining rainfall
+type WindMode string
+
+const (
+	// WindBands uses latitude based wind bands; easterly trade winds near
+	// the equator, westerlies in the mid latitudes & polar easterlies
+	WindBands WindMode = "bands"
+
+	// WindFixed blows the wind in a single direction (rainfallSettings.WindHeading)
+	WindFixed WindMode = "fixed"
+
+	// WindNone ignores wind, rainfall is noise & fresh water only
+	WindNone WindMode = "none"
+)
+
+// latitude returns the latitude in degrees (0-90) from the equator, which
+// is the middle row of the map, of row dy (of y rows)
+func latitude(dy, y int) float64 {
+	equator := float64(y) / 2
+	return math.Abs(float64(dy)-equator) / equator * 90
+}
+
+// windHeading returns the heading the wind blows towards at the given latitude
+func windHeading(rs *rainfallSettings, lat float64) shapes.Heading {
+	if rs.Wind == WindFixed {
+		return rs.WindHeading
+	}
+
+	switch {
+	case lat < 30:
+		return shapes.WEST // trade winds blow from the east
+	case lat < 60:
+		return shapes.EAST // westerlies
+	default:
+		return shapes.WEST // polar easterlies
+	}
+}
+
+// windRainfall carries moisture over the map on the prevailing winds.
+// Air picks up moisture over the sea, slowly rains it out over land & drops
+// much of it as it is forced up over high ground, leaving the lee side of
+// mountains dry (a rain shadow).
+// Returns rainfall 0-255 for each pixel.
+func windRainfall(t *tracker, hmap, sea, jitter *MapImage, sealevel uint8, rs *rainfallSettings) ([]float64, error) {
+	x, y := hmap.Dimensions()
+	level := float64(sealevel)
+
+	height := func(dx, dy int) float64 {
+		h := hmap.Float(dx, dy)
+		if sea.Value(dx, dy) == 255 || h < level {
+			return level // air moves over the sea surface
+		}
+		return h
+	}
+
+	// figure out the wind at each pixel & order pixels so that those
+	// upwind of any pixel come before it
+	headings := make([]shapes.Heading, x*y)
+	order := make([]int, x*y)
+	keys := make([]int, x*y)
+	for i := range order {
+		dx := i % x
+		dy := i / x
+		// the boundaries between wind bands are moved about by up to
+		// 8 degrees of latitude by our jitter map
+		lat := latitude(dy, y) + (jitter.Float(dx, dy)-127.5)/127.5*8
+		headings[i] = windHeading(rs, lat)
+		wx, wy := headings[i].RiseRun()
+		keys[i] = dx*wx + dy*wy
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return keys[order[i]] < keys[order[j]] })
+
+	moisture := make([]float64, x*y)
+	rain := make([]float64, x*y)
+
+	// if we wrap east-west air arriving over the west edge of the map comes
+	// from the east edge, which we've not reached yet on the first pass. So
+	// we go around twice, the second pass starting with the air from the first
+	passes := 1
+	if hmap.wrapX {
+		passes = 2
+	}
+
+	for pass := 0; pass < passes; pass++ {
+		for n, i := range order {
+			if n%x == 0 {
+				err := t.Progress(float64(pass*len(order)+n) / float64(passes*len(order)))
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			dx := i % x
+			dy := i / x
+			wx, wy := headings[i].RiseRun()
+			ux := hmap.wrap(dx - wx)
+			uy := dy - wy
+
+			// moisture & height of the air arriving from upwind, we average over
+			// the pixels either side of upwind (with the same wind) so rain
+			// spreads out a little rather than following perfect lines
+			m := 0.0
+			h := height(dx, dy)
+			if ux >= 0 && uy >= 0 && ux < x && uy < y {
+				h = height(ux, uy)
+
+				count := 0.0
+				for _, side := range []int{-1, 0, 1} {
+					sx := hmap.wrap(ux - wy*side)
+					sy := uy + wx*side
+					if sx < 0 || sy < 0 || sx >= x || sy >= y || headings[sy*x+sx] != headings[i] {
+						continue
+					}
+					m += moisture[sy*x+sx]
+					count++
+				}
+				if count > 0 {
+					m /= count
+				}
+			}
+
+			if sea.Value(dx, dy) == 255 {
+				// pick up moisture over the sea
+				m += (255 - m) * rs.Evaporation
+				rain[i] = m * 0.5
+				moisture[i] = m
+				continue
+			}
+
+			// some rain falls everywhere, more where the air is forced to rise
+			drop := m * rs.Precipitation
+			rise := height(dx, dy) - h
+			if rise > 0 {
+				drop += m * math.Min(1, rise*rs.Orographic)
+			}
+			if drop > m {
+				drop = m
+			}
+
+			rain[i] = m*0.5 + drop*30
+			moisture[i] = m - drop
+		}
+
+	}
+
+	return rain, nil
+}
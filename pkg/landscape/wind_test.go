@@ -0,0 +1,61 @@
+package landscape
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voidshard/cartographer/pkg/shapes"
+)
+
+func TestWindHeading(t *testing.T) {
+	rs := DefaultConfig().Rain
+	assert.Equal(t, 0.0, latitude(90, 180))
+	assert.Equal(t, 45.0, latitude(45, 180))
+	assert.Equal(t, 45.0, latitude(135, 180))
+	assert.Equal(t, 90.0, latitude(0, 180))
+
+	assert.Equal(t, shapes.WEST, windHeading(rs, 10)) // trade winds
+	assert.Equal(t, shapes.EAST, windHeading(rs, 45)) // westerlies
+	assert.Equal(t, shapes.WEST, windHeading(rs, 80)) // polar easterlies
+
+	rs.Wind = WindFixed
+	rs.WindHeading = shapes.SOUTH
+	assert.Equal(t, shapes.SOUTH, windHeading(rs, 10))
+}
+
+func TestWindRainShadow(t *testing.T) {
+	rs := DefaultConfig().Rain
+	rs.Wind = WindFixed
+	rs.WindHeading = shapes.EAST
+
+	// sea in the west, then flat land with a ridge running north-south
+	x, y := 120, 20
+	hmap := NewMapImage16(x, y)
+	sea := NewMapImage(x, y)
+	for dx := 0; dx < x; dx++ {
+		for dy := 0; dy < y; dy++ {
+			switch {
+			case dx < 20:
+				hmap.SetValue(dx, dy, 100)
+				sea.SetValue(dx, dy, 255)
+			case dx >= 50 && dx < 70:
+				hmap.SetValue(dx, dy, uint8(130+(dx-50)*2)) // climbing
+			case dx >= 70 && dx < 90:
+				hmap.SetValue(dx, dy, uint8(170-(dx-70)*2)) // descending
+			default:
+				hmap.SetValue(dx, dy, 130)
+			}
+		}
+	}
+	jitter := NewMapImage(x, y)
+
+	tr := newReporter(context.Background(), nil).stage(StageRainfall)
+	rain, err := windRainfall(tr, hmap, sea, jitter, 115, rs)
+	assert.Nil(t, err)
+
+	at := func(dx int) float64 { return rain[(y/2)*x+dx] }
+	assert.True(t, at(60) > at(40), "windward slopes are wetter than flat land")
+	assert.True(t, at(100) < at(40), "lee side is drier than the windward side")
+	assert.True(t, at(21) > at(45), "rain falls as air moves inland")
+}
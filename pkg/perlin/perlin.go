@@ -23,14 +23,17 @@ type noise2DContext struct {
 	permutations []int
 	gradients    [4]vec2
 	origins      [4]vec2
+
+	// if > 0 the noise repeats every `period` units along the X axis
+	period int
 }
 
-func newNoise2DContext(seed int) *noise2DContext {
-	rnd := rand.New(rand.NewSource(int64(seed)))
+func newNoise2DContext(seed int64) *noise2DContext {
+	rnd := rand.New(rand.NewSource(seed))
 
 	n2d := new(noise2DContext)
 	n2d.rgradients = make([]vec2, 256)
-	n2d.permutations = rand.Perm(256)
+	n2d.permutations = rnd.Perm(256)
 	for i := range n2d.rgradients {
 		n2d.rgradients[i] = random_gradient(rnd)
 	}
@@ -44,41 +47,137 @@ func newNoise2DContext(seed int) *noise2DContext {
 // being increasingly chaotic. Scale here is intended to be positive only, and we use
 // it's absolute value.
 func Perlin(fx, fy int, scale float64) *image.RGBA {
+	return PerlinSeed(fx, fy, scale, time.Now().UnixNano())
+}
+
+// PerlinSeed is Perlin but with an explicit seed. Given the same arguments
+// the same image is always returned.
+func PerlinSeed(fx, fy int, scale float64, seed int64) *image.RGBA {
 	x, y := sanitize(fx, fy, scale)
 
-	noise := generate2DNoise(0, x, 0, y, ITTERATIONS, int(time.Now().UnixNano()))
+	noise := normalisedNoise(x, y, seed)
 	im := image.NewRGBA(image.Rect(0, 0, x, y))
 
-	var max float32 = 0
-	var min float32 = 1
 	for dx := 0; dx < x; dx++ {
 		for dy := 0; dy < y; dy++ {
-			n := noise[(dy*x)+dx]
-			if n > max {
-				max = n
-			}
-			if n < min {
-				min = n
-			}
+			cv := uint8(noise[(dy*x)+dx] * 255)
+			im.Set(dx, dy, color.RGBA{cv, cv, cv, 255})
 		}
 	}
 
+	if fx != x || fy != y {
+		return (resizeImage(float64(fx), float64(fy), im)).(*image.RGBA)
+	}
+
+	return im
+}
+
+// PerlinSeed16 is PerlinSeed but returns a 16 bit greyscale image with
+// colours 0-65535, for when 256 levels are too coarse.
+func PerlinSeed16(fx, fy int, scale float64, seed int64) *image.Gray16 {
+	x, y := sanitize(fx, fy, scale)
+
+	noise := normalisedNoise(x, y, seed)
+	im := image.NewGray16(image.Rect(0, 0, x, y))
+
 	for dx := 0; dx < x; dx++ {
 		for dy := 0; dy < y; dy++ {
-			n := (noise[(dy*x)+dx] - min) * (1 / (max - min))
-			cv := uint8(n * 255)
-			im.Set(dx, dy, color.RGBA{cv, cv, cv, 255})
+			im.SetGray16(dx, dy, color.Gray16{uint16(noise[(dy*x)+dx] * 65535)})
 		}
 	}
 
 	if fx != x || fy != y {
-		return (resizeImage(float64(fx), float64(fy), im)).(*image.RGBA)
+		return (resizeImage(float64(fx), float64(fy), im)).(*image.Gray16)
+	}
+
+	return im
+}
+
+// PerlinSeedWrapX is PerlinSeed but the noise tiles along the X axis, so the
+// left edge of the image carries on seamlessly from the right edge.
+func PerlinSeedWrapX(fx, fy int, scale float64, seed int64) *image.RGBA {
+	noise := wrappedNoise(fx, fy, scale, seed)
+	im := image.NewRGBA(image.Rect(0, 0, fx, fy))
+
+	for dx := 0; dx < fx; dx++ {
+		for dy := 0; dy < fy; dy++ {
+			cv := uint8(noise[(dy*fx)+dx] * 255)
+			im.Set(dx, dy, color.RGBA{cv, cv, cv, 255})
+		}
 	}
 
 	return im
 }
 
-func generate2DNoise(x, w, y, h, itterations, seed int) []float32 {
+// PerlinSeed16WrapX is PerlinSeed16 but the noise tiles along the X axis
+// (see PerlinSeedWrapX).
+func PerlinSeed16WrapX(fx, fy int, scale float64, seed int64) *image.Gray16 {
+	noise := wrappedNoise(fx, fy, scale, seed)
+	im := image.NewGray16(image.Rect(0, 0, fx, fy))
+
+	for dx := 0; dx < fx; dx++ {
+		for dy := 0; dy < fy; dy++ {
+			im.SetGray16(dx, dy, color.Gray16{uint16(noise[(dy*fx)+dx] * 65535)})
+		}
+	}
+
+	return im
+}
+
+// wrappedNoise returns noise of size (fx,fy) scaled to 0-1 that repeats along
+// the X axis. Rather than generating a small map & resizing it (which would
+// break the seam) we sample the noise at every pixel, with the width of the
+// map being a whole number of noise cells.
+func wrappedNoise(fx, fy int, scale float64, seed int64) []float32 {
+	x, y := sanitize(fx, fy, scale)
+
+	n2d := newNoise2DContext(seed)
+	n2d.period = int(math.Round(float64(x) * 0.1))
+	if n2d.period < 1 {
+		n2d.period = 1
+	}
+
+	noise := make([]float32, fx*fy)
+	for dx := 0; dx < fx; dx++ {
+		for dy := 0; dy < fy; dy++ {
+			v := n2d.Get(
+				float32(dx)/float32(fx)*float32(n2d.period),
+				float32(dy)/float32(fy)*float32(y)*0.1,
+			)
+			noise[dy*fx+dx] = v*0.5 + 0.5
+		}
+	}
+
+	normalise(noise)
+	return noise
+}
+
+// normalisedNoise returns noise of size (x,y) scaled to 0-1
+func normalisedNoise(x, y int, seed int64) []float32 {
+	noise := generate2DNoise(0, x, 0, y, ITTERATIONS, seed)
+	normalise(noise)
+	return noise
+}
+
+// normalise scales the given noise to 0-1
+func normalise(noise []float32) {
+	var max float32 = 0
+	var min float32 = 1
+	for _, n := range noise {
+		if n > max {
+			max = n
+		}
+		if n < min {
+			min = n
+		}
+	}
+
+	for i, n := range noise {
+		noise[i] = (n - min) * (1 / (max - min))
+	}
+}
+
+func generate2DNoise(x, w, y, h, itterations int, seed int64) []float32 {
 	dx := w - x
 	dy := h - y
 
@@ -120,6 +219,9 @@ func gradient(orig, grad, p vec2) float32 {
 }
 
 func (n2d *noise2DContext) get_gradient(x, y int) vec2 {
+	if n2d.period > 0 {
+		x = ((x % n2d.period) + n2d.period) % n2d.period
+	}
 	idx := n2d.permutations[x&255] + n2d.permutations[y&255]
 	return n2d.rgradients[idx&255]
 }
@@ -9,18 +9,52 @@ import (
 )
 
 var (
-	rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	std = New(time.Now().UnixNano())
 )
 
+// Rand wraps a math/rand Rand with our shape / point helpers.
+// A Rand created with a given seed always produces the same results.
+// Nb. a Rand is not safe for concurrent use.
+type Rand struct {
+	rng *rand.Rand
+}
+
+// New returns a Rand seeded with the given value
+func New(seed int64) *Rand {
+	return &Rand{rng: rand.New(rand.NewSource(seed))}
+}
+
 // Int wraps math/rand Rand.Intn
 func Int(a int) int {
-	return rng.Intn(a)
+	return std.Int(a)
 }
 
 //
 func Voronoi(sites int, mindist float64, poly *shapes.Polygon) *voronoi.Graph {
+	return std.Voronoi(sites, mindist, poly)
+}
+
+// PointsMinDist returns at most `sites` points at least `mindist` apart
+// within `poly`
+func PointsMinDist(sites int, mindist float64, poly *shapes.Polygon) []*shapes.Point {
+	return std.PointsMinDist(sites, mindist, poly)
+}
+
+// Points returns at most `sites` within `poly` indented by approximately
+// `indent` (that is, points will not be placed around the edges)
+func Points(sites int, indent int, poly *shapes.Polygon) []*shapes.Point {
+	return std.Points(sites, indent, poly)
+}
+
+// Int wraps math/rand Rand.Intn
+func (r *Rand) Int(a int) int {
+	return r.rng.Intn(a)
+}
+
+// Voronoi returns a voronoi diagram of at most `sites` cells within `poly`
+func (r *Rand) Voronoi(sites int, mindist float64, poly *shapes.Polygon) *voronoi.Graph {
 	for {
-		pnts := PointsMinDist(sites, mindist, poly)
+		pnts := r.PointsMinDist(sites, mindist, poly)
 
 		// our voronoi lib under some odd circumstances panics when
 		// handling certain configurations of points if we managed to
@@ -38,13 +72,13 @@ func Voronoi(sites int, mindist float64, poly *shapes.Polygon) *voronoi.Graph {
 
 // PointsMinDist returns at most `sites` points at least `mindist` apart
 // within `poly`
-func PointsMinDist(sites int, mindist float64, poly *shapes.Polygon) []*shapes.Point {
+func (r *Rand) PointsMinDist(sites int, mindist float64, poly *shapes.Polygon) []*shapes.Point {
 	x0, y0, x1, y1 := poly.Bounds()
 
 	pts := []*shapes.Point{}
 
 	for i := 0; i < sites; i++ {
-		pt := shapes.Pt(rng.Float64()*(x1-x0)+x0, rng.Float64()*(y1-y0)+y0)
+		pt := shapes.Pt(r.rng.Float64()*(x1-x0)+x0, r.rng.Float64()*(y1-y0)+y0)
 		if !poly.Contains(pt) {
 			continue
 		}
@@ -71,7 +105,7 @@ func PointsMinDist(sites int, mindist float64, poly *shapes.Polygon) []*shapes.P
 
 // Points returns at most `sites` within `poly` indented by approximately
 // `indent` (that is, points will not be placed around the edges)
-func Points(sites int, indent int, poly *shapes.Polygon) []*shapes.Point {
+func (r *Rand) Points(sites int, indent int, poly *shapes.Polygon) []*shapes.Point {
 	hbuff := float64(indent / 2)
 
 	x0, y0, x1, y1 := poly.Bounds()
@@ -79,8 +113,8 @@ func Points(sites int, indent int, poly *shapes.Polygon) []*shapes.Point {
 	pts := []*shapes.Point{}
 	for i := 0; i < sites; i++ {
 		pt := shapes.Pt(
-			rng.Float64()*(x1-x0-float64(indent))+x0+hbuff,
-			rng.Float64()*(y1-y0-float64(indent))+y0+hbuff,
+			r.rng.Float64()*(x1-x0-float64(indent))+x0+hbuff,
+			r.rng.Float64()*(y1-y0-float64(indent))+y0+hbuff,
 		)
 		if poly.Contains(pt) {
 			pts = append(pts, pt)